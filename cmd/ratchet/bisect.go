@@ -0,0 +1,176 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/spf13/cobra"
+	"github.com/tiernacity/ratchet/internal/config"
+	"github.com/tiernacity/ratchet/internal/ratchet"
+)
+
+var (
+	// Bisect search settings
+	bisectGood      string
+	bisectBad       string
+	bisectDirection string
+	bisectThreshold string
+	bisectName      string
+)
+
+var bisectCmd = &cobra.Command{
+	Use:   "bisect --good <ref> --direction lower|higher [metric command]",
+	Short: "Find the commit that regressed a metric",
+	Long: `Find the first commit between a good and a bad ref at which a metric got
+worse, by binary search. Each commit is measured in a temporary worktree, so
+the working copy and any git bisect session in progress are not touched.
+
+A commit is good if its value is no worse than the threshold, which defaults
+to the value at the good ref, allowing for any tolerance.`,
+	Example: `  ratchet bisect --good v1.4.0 --bad main --direction lower 'grep -r TODO . | wc -l'
+  ratchet bisect --good main~50 --direction higher --threshold 80 --name coverage`,
+	Args:          usageArgs(cobra.MaximumNArgs(1)),
+	SilenceUsage:  true,
+	SilenceErrors: true,
+	RunE:          runBisect,
+}
+
+func runBisect(cmd *cobra.Command, args []string) error {
+	var metric string
+	if len(args) > 0 {
+		metric = args[0]
+	}
+
+	if bisectGood == "" {
+		return usageErrorf("--good is required")
+	}
+	var ct ratchet.ComparisonType
+	switch bisectDirection {
+	case "lower":
+		ct = ratchet.LessEqual
+	case "higher":
+		ct = ratchet.GreaterEqual
+	default:
+		return usageErrorf("--direction must be lower or higher, whichever is better for the metric")
+	}
+	var threshold *float64
+	if bisectThreshold != "" {
+		v, err := strconv.ParseFloat(bisectThreshold, 64)
+		if err != nil {
+			return usageErrorf("invalid threshold '%s': expected a number", bisectThreshold)
+		}
+		threshold = &v
+	}
+
+	cfg, err := loadConfig()
+	if err != nil {
+		return err
+	}
+	cfg.MergeWithFlags(config.Flags{
+		Metric:      metric,
+		Pre:         pre,
+		Post:        post,
+		Verbose:     verbose,
+		CacheDir:    cacheDir,
+		NoCache:     noCache,
+		NotesRead:   notesRead,
+		NotesWrite:  notesWrite,
+		NotesRemote: notesRemote,
+		Tolerance:   tolerance,
+		Timeout:     timeout,
+	})
+	if err := validateConfig(cfg); err != nil {
+		return err
+	}
+
+	metrics, err := buildMetrics(cfg)
+	if err != nil {
+		return err
+	}
+	m, err := selectMetric(metrics, bisectName)
+	if err != nil {
+		return err
+	}
+	m.ComparisonType = ct
+
+	resultCache, err := openCache(cfg, false)
+	if err != nil {
+		return err
+	}
+
+	opts := ratchet.BisectOptions{
+		Metric:    m,
+		Good:      bisectGood,
+		Bad:       bisectBad,
+		Threshold: threshold,
+		Cache:     resultCache,
+		Notes: ratchet.NotesOptions{
+			Read:   cfg.Notes.Read,
+			Write:  cfg.Notes.Write,
+			Remote: cfg.Notes.Remote,
+		},
+	}
+	if cfg.Verbose {
+		opts.Progress = os.Stderr
+	}
+
+	res, err := ratchet.Bisect(opts)
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("%s is the first bad commit\n", res.First.SHA)
+	fmt.Printf("  %s\n", res.First.Subject)
+	var before []string
+	for i, parent := range res.Parents {
+		before = append(before, fmt.Sprintf("%g at %s", res.Before[i], parent.SHA[:10]))
+	}
+	fmt.Printf("  before: %s\n", strings.Join(before, ", "))
+	fmt.Printf("  after:  %g, which is NOT %s %g\n", res.After, res.Description, res.Limit)
+	fmt.Printf("Tested %d commits\n", res.Tested)
+	return nil
+}
+
+// selectMetric picks the named metric, or the only one if name is empty
+func selectMetric(metrics []ratchet.Metric, name string) (ratchet.Metric, error) {
+	if name == "" {
+		if len(metrics) > 1 {
+			return ratchet.Metric{}, usageErrorf("the config defines several metrics; choose one with --name")
+		}
+		return metrics[0], nil
+	}
+	for _, m := range metrics {
+		if m.Name == name {
+			return m, nil
+		}
+	}
+	return ratchet.Metric{}, usageErrorf("no metric named '%s' in the config", name)
+}
+
+func init() {
+	bisectCmd.Flags().StringVar(&bisectGood, "good", "", "ref at which the metric is known to be good")
+	bisectCmd.Flags().StringVar(&bisectBad, "bad", "HEAD", "ref at which the metric is known to be bad")
+	bisectCmd.Flags().StringVar(&bisectDirection, "direction", "", "which values are better: lower or higher")
+	bisectCmd.Flags().StringVar(&bisectThreshold, "threshold", "", "value a good commit must be no worse than (default: value at --good)")
+	bisectCmd.Flags().StringVar(&tolerance, "tolerance", "", "allow a good commit to be worse by an absolute amount (0.5) or percentage (1%)")
+	bisectCmd.Flags().StringVar(&bisectName, "name", "", "metric to bisect when the config defines several")
+
+	bisectCmd.Flags().StringVar(&pre, "pre", "", "command to run before metric command")
+	bisectCmd.Flags().StringVar(&post, "post", "", "command to run after metric command, even if it fails")
+	bisectCmd.Flags().StringVar(&timeout, "timeout", "", "kill any pre, metric or post command that runs longer than this (e.g. 10m)")
+	bisectCmd.Flags().StringVar(&configFile, "config-file", "", "path to config file (YAML or JSON)")
+	bisectCmd.Flags().StringVar(&configStr, "config", "", "config string (YAML or JSON)")
+	bisectCmd.Flags().StringVar(&cacheDir, "cache-dir", "", "directory for cached results (default: user cache dir)")
+	bisectCmd.Flags().BoolVar(&noCache, "no-cache", false, "always measure instead of using cached results")
+	bisectCmd.Flags().BoolVar(&notesRead, "notes-read", false, "use values recorded in refs/notes/ratchet when present")
+	bisectCmd.Flags().BoolVar(&notesWrite, "notes-write", false, "record measured values in refs/notes/ratchet")
+	bisectCmd.Flags().StringVar(&notesRemote, "notes-remote", "", "remote to fetch notes from and push notes to")
+	bisectCmd.Flags().BoolVarP(&verbose, "verbose", "v", false, "print each tested commit's value on stderr")
+
+	// The root command's usage lists its own flags, so use cobra's default here
+	bisectCmd.SetUsageTemplate((&cobra.Command{}).UsageTemplate())
+
+	rootCmd.AddCommand(bisectCmd)
+}
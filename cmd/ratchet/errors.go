@@ -0,0 +1,77 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/spf13/cobra"
+	"github.com/tiernacity/ratchet/internal/executor"
+	"github.com/tiernacity/ratchet/internal/ratchet"
+)
+
+// Exit codes, as documented in SPEC.md. Those for git, step and interrupted
+// failures mark runs that may pass if retried, unlike a regression.
+const (
+	exitRegression  = 1   // A comparison failed
+	exitUsage       = 2   // Invalid flags, arguments or config
+	exitGit         = 3   // A git operation failed, such as fetching a base ref
+	exitStep        = 4   // A pre, metric or post command failed or timed out
+	exitParse       = 5   // Metric output did not hold a value
+	exitError       = 6   // Any other error
+	exitInterrupted = 130 // Interrupted by a signal, as for SIGINT
+)
+
+// usageError is an invalid command line or config
+type usageError struct {
+	err       error
+	showUsage bool // Whether the usage of the command helps explain the error
+}
+
+func (e *usageError) Error() string {
+	return e.err.Error()
+}
+
+func (e *usageError) Unwrap() error {
+	return e.err
+}
+
+// usageErrorf returns a usage error formatted as by fmt.Errorf
+func usageErrorf(format string, args ...interface{}) error {
+	return &usageError{err: fmt.Errorf(format, args...)}
+}
+
+// usageArgs makes the errors of an argument validator usage errors
+func usageArgs(args cobra.PositionalArgs) cobra.PositionalArgs {
+	return func(cmd *cobra.Command, a []string) error {
+		if err := args(cmd, a); err != nil {
+			return &usageError{err: err, showUsage: true}
+		}
+		return nil
+	}
+}
+
+// exitCode chooses the exit code for an error returned by a command. An
+// interruption explains any failure it caused, and a failure to measure
+// explains a missing comparison.
+func exitCode(err error) int {
+	var usage *usageError
+	var gitErr *ratchet.GitError
+	var stepErr *ratchet.StepError
+	var parseErr *ratchet.ParseError
+	switch {
+	case errors.Is(err, executor.ErrInterrupted):
+		return exitInterrupted
+	case errors.As(err, &usage):
+		return exitUsage
+	case errors.As(err, &stepErr):
+		return exitStep
+	case errors.As(err, &gitErr):
+		return exitGit
+	case errors.As(err, &parseErr):
+		return exitParse
+	case errors.Is(err, ratchet.ErrComparisonFailed):
+		return exitRegression
+	default:
+		return exitError
+	}
+}
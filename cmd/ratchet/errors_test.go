@@ -0,0 +1,56 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/spf13/cobra"
+	"github.com/tiernacity/ratchet/internal/executor"
+	"github.com/tiernacity/ratchet/internal/ratchet"
+)
+
+func TestExitCode(t *testing.T) {
+	step := &ratchet.StepError{Step: "metric", Command: "false", Side: ratchet.SideHead, Where: "working copy", Err: errors.New("exit status 1")}
+	timedOut := &ratchet.StepError{Step: "pre", Command: "sleep 9", Side: ratchet.SideBase, Where: "main", Timeout: time.Second, Err: context.DeadlineExceeded}
+	interrupted := &ratchet.StepError{Step: "metric", Command: "sleep 9", Side: ratchet.SideHead, Where: "working copy", Err: executor.ErrInterrupted}
+	git := &ratchet.GitError{Err: errors.New("fatal: bad revision")}
+	parse := &ratchet.ParseError{Err: errors.New("not a number")}
+
+	tests := []struct {
+		name string
+		err  error
+		want int
+	}{
+		{"regression", fmt.Errorf("1 of 2 metrics: %w", ratchet.ErrComparisonFailed), exitRegression},
+		{"usage", usageErrorf("unknown metric %q", "x"), exitUsage},
+		{"usage from arguments", usageArgs(func(*cobra.Command, []string) error { return errors.New("too many") })(nil, nil), exitUsage},
+		{"git", git, exitGit},
+		{"step", step, exitStep},
+		{"timeout", timedOut, exitStep},
+		{"parse", parse, exitParse},
+		{"other", errors.New("disk full"), exitError},
+		{"interrupted", executor.ErrInterrupted, exitInterrupted},
+
+		// Wrapped, as the results are reported
+		{"reported step", &ratchet.ReportedError{Err: step}, exitStep},
+		{"wrapped git", fmt.Errorf("fetching main: %w", git), exitGit},
+		{"wrapped parse", &ratchet.ReportedError{Err: fmt.Errorf("metric x: %w", parse)}, exitParse},
+		{"wrapped usage", fmt.Errorf("config: %w", usageErrorf("bad")), exitUsage},
+
+		// The first failure to explain the others decides
+		{"interrupted step", interrupted, exitInterrupted},
+		{"interrupted with regression", errors.Join(ratchet.ErrComparisonFailed, &ratchet.ReportedError{Err: interrupted}), exitInterrupted},
+		{"step with regression", errors.Join(ratchet.ErrComparisonFailed, step), exitStep},
+		{"parse with regression", errors.Join(ratchet.ErrComparisonFailed, parse), exitParse},
+		{"step before git", errors.Join(git, step), exitStep},
+	}
+
+	for _, tt := range tests {
+		if got := exitCode(tt.err); got != tt.want {
+			t.Errorf("%s: exitCode(%v) = %d, want %d", tt.name, tt.err, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,96 @@
+package main
+
+import (
+	"errors"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/tiernacity/ratchet/internal/ratchet"
+)
+
+// TestGitHubActionsFiles runs ratchet as a workflow step would, with
+// GITHUB_OUTPUT and GITHUB_STEP_SUMMARY pointing at temporary files
+func TestGitHubActionsFiles(t *testing.T) {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git not available")
+	}
+	t.Setenv("GIT_AUTHOR_NAME", "test")
+	t.Setenv("GIT_AUTHOR_EMAIL", "test@example.com")
+	t.Setenv("GIT_COMMITTER_NAME", "test")
+	t.Setenv("GIT_COMMITTER_EMAIL", "test@example.com")
+
+	repo := t.TempDir()
+	git := func(args ...string) {
+		t.Helper()
+		cmd := exec.Command("git", args...)
+		cmd.Dir = repo
+		if out, err := cmd.CombinedOutput(); err != nil {
+			t.Fatalf("git %v: %v\n%s", args, err, out)
+		}
+	}
+	write := func(name string, content string) {
+		t.Helper()
+		if err := os.WriteFile(filepath.Join(repo, name), []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// main records 2 warnings; the working copy has 5
+	write("warnings", "2\n")
+	write(".ratchet", "le: main\nmetrics:\n  warnings:\n    metric: cat warnings\n  ok:\n    metric: echo 1\n")
+	git("init", "-q", "-b", "main")
+	git("add", ".")
+	git("commit", "-q", "-m", "init")
+	git("checkout", "-q", "-b", "feature")
+	write("warnings", "5\n")
+
+	wd, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chdir(repo); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { os.Chdir(wd) })
+
+	outputs := filepath.Join(t.TempDir(), "output")
+	summary := filepath.Join(t.TempDir(), "summary")
+	t.Setenv("GITHUB_ACTIONS", "true")
+	t.Setenv("GITHUB_OUTPUT", outputs)
+	t.Setenv("GITHUB_STEP_SUMMARY", summary)
+
+	rootCmd.SetArgs(nil)
+	err = rootCmd.Execute()
+	if !errors.Is(err, ratchet.ErrComparisonFailed) {
+		t.Fatalf("got error %v, want the comparison to fail", err)
+	}
+
+	data, err := os.ReadFile(outputs)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := "warnings-base-value=2\nwarnings-head-value=5\nwarnings-passed=false\n" +
+		"ok-base-value=1\nok-head-value=1\nok-passed=true\n" +
+		"passed=false\n"
+	if string(data) != want {
+		t.Errorf("GITHUB_OUTPUT got\n%s\nwant\n%s", data, want)
+	}
+
+	data, err = os.ReadFile(summary)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, line := range []string{
+		"## Ratchet",
+		"| warnings | `main` | 2 | 5 | <= | :x: failed |",
+		"| ok | `main` | 1 | 1 | <= | :white_check_mark: passed |",
+		"- **warnings**: feature metric (5) is NOT less than or equal to main (2)",
+	} {
+		if !strings.Contains(string(data), line+"\n") {
+			t.Errorf("GITHUB_STEP_SUMMARY missing %q:\n%s", line, data)
+		}
+	}
+}
@@ -0,0 +1,164 @@
+package main
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/spf13/cobra"
+	"github.com/tiernacity/ratchet/internal/config"
+	"github.com/tiernacity/ratchet/internal/ratchet"
+	"github.com/tiernacity/ratchet/internal/report"
+)
+
+var (
+	// History selection and output
+	historyRange  string
+	historySince  string
+	historyEvery  int
+	historyJobs   int
+	historyOutput string
+	historyFirst  bool
+)
+
+var historyCmd = &cobra.Command{
+	Use:   "history [metric command]",
+	Short: "Measure a metric at each commit in a range",
+	Long: `Measure a metric at each commit in a range and print the series as a
+table, CSV or JSON. Results already stored in the cache or in git notes are
+reused, so only commits without one are checked out and measured.`,
+	Example: `  ratchet history --range main~200..main 'grep -r TODO . | wc -l'
+  ratchet history --since 2024-01-01 --every 10 --output csv --jobs 4`,
+	Args:          usageArgs(cobra.MaximumNArgs(1)),
+	SilenceUsage:  true,
+	SilenceErrors: true,
+	RunE:          runHistory,
+}
+
+func runHistory(cmd *cobra.Command, args []string) error {
+	var metric string
+	if len(args) > 0 {
+		metric = args[0]
+	}
+
+	cfg, err := loadConfig()
+	if err != nil {
+		return err
+	}
+	cfg.MergeWithFlags(config.Flags{
+		Metric:      metric,
+		Pre:         pre,
+		Post:        post,
+		Verbose:     verbose,
+		CacheDir:    cacheDir,
+		NoCache:     noCache,
+		NotesRead:   notesRead,
+		NotesWrite:  notesWrite,
+		NotesRemote: notesRemote,
+		Timeout:     timeout,
+	})
+	if err := validateConfig(cfg); err != nil {
+		return err
+	}
+
+	metrics, err := buildMetrics(cfg)
+	if err != nil {
+		return err
+	}
+
+	if historyOutput != "table" && historyOutput != "csv" && historyOutput != "json" {
+		return usageErrorf("invalid output format '%s': expected table, csv or json", historyOutput)
+	}
+	if historyEvery < 1 {
+		return usageErrorf("--every must be at least 1")
+	}
+	if historyJobs < 1 {
+		return usageErrorf("--jobs must be at least 1")
+	}
+
+	resultCache, err := openCache(cfg, false)
+	if err != nil {
+		return err
+	}
+
+	opts := ratchet.HistoryOptions{
+		Metrics:     metrics,
+		Range:       historyRange,
+		Since:       historySince,
+		FirstParent: historyFirst,
+		Every:       historyEvery,
+		Jobs:        historyJobs,
+		Cache:       resultCache,
+		Notes: ratchet.NotesOptions{
+			Read:   cfg.Notes.Read,
+			Write:  cfg.Notes.Write,
+			Remote: cfg.Notes.Remote,
+		},
+	}
+	if cfg.Verbose {
+		opts.Progress = os.Stderr
+	}
+
+	points, err := ratchet.History(opts)
+	if err != nil {
+		return err
+	}
+
+	switch historyOutput {
+	case "csv":
+		err = report.WriteHistoryCSV(os.Stdout, metrics, points)
+	case "json":
+		err = report.WriteHistoryJSON(os.Stdout, metrics, points)
+	default:
+		err = report.WriteHistoryTable(os.Stdout, metrics, points)
+	}
+	if err != nil {
+		return err
+	}
+
+	// Explain every value that could not be obtained
+	failed, total := 0, 0
+	for _, p := range points {
+		for i, v := range p.Values {
+			total++
+			if v.Err == nil {
+				continue
+			}
+			failed++
+			if name := metrics[i].Name; name != "" {
+				fmt.Fprintf(os.Stderr, "%s %s: %v\n", p.Commit.SHA[:10], name, v.Err)
+			} else {
+				fmt.Fprintf(os.Stderr, "%s: %v\n", p.Commit.SHA[:10], v.Err)
+			}
+		}
+	}
+	if failed > 0 {
+		return fmt.Errorf("could not measure %d of %d values", failed, total)
+	}
+	return nil
+}
+
+func init() {
+	historyCmd.Flags().StringVar(&historyRange, "range", "", "revision range to walk, e.g. main~200..main (default: history of HEAD)")
+	historyCmd.Flags().StringVar(&historySince, "since", "", "only commits more recent than this date, e.g. 2024-01-01 or \"3 months ago\"")
+	historyCmd.Flags().BoolVar(&historyFirst, "first-parent", true, "follow only the first parent of merges; false also lists the commits of merged branches")
+	historyCmd.Flags().IntVar(&historyEvery, "every", 1, "only measure every Nth commit, counting back from the newest")
+	historyCmd.Flags().IntVar(&historyJobs, "jobs", 1, "number of commits to measure at the same time")
+	historyCmd.Flags().StringVar(&historyOutput, "output", "table", "output format: table, csv or json")
+
+	historyCmd.Flags().StringVar(&pre, "pre", "", "command to run before metric command")
+	historyCmd.Flags().StringVar(&post, "post", "", "command to run after metric command, even if it fails")
+	historyCmd.Flags().StringVar(&timeout, "timeout", "", "kill any pre, metric or post command that runs longer than this (e.g. 10m)")
+	historyCmd.Flags().StringVar(&configFile, "config-file", "", "path to config file (YAML or JSON)")
+	historyCmd.Flags().StringVar(&configStr, "config", "", "config string (YAML or JSON)")
+	historyCmd.Flags().StringVar(&cacheDir, "cache-dir", "", "directory for cached results (default: user cache dir)")
+	historyCmd.Flags().BoolVar(&noCache, "no-cache", false, "always measure instead of using cached results")
+	historyCmd.Flags().BoolVar(&notesRead, "notes-read", false, "use values recorded in refs/notes/ratchet when present")
+	historyCmd.Flags().BoolVar(&notesWrite, "notes-write", false, "record measured values in refs/notes/ratchet")
+	historyCmd.Flags().StringVar(&notesRemote, "notes-remote", "", "remote to fetch notes from and push notes to")
+	historyCmd.Flags().BoolVarP(&verbose, "verbose", "v", false, "print each commit's values on stderr as it is measured")
+
+	// The root command's usage lists its own flags, so use cobra's default here
+	historyCmd.SetUsageTemplate((&cobra.Command{}).UsageTemplate())
+
+	rootCmd.AddCommand(historyCmd)
+}
@@ -1,12 +1,23 @@
 package main
 
 import (
+	"bytes"
+	"errors"
 	"fmt"
+	"io"
 	"os"
+	"strconv"
+	"strings"
 
 	"github.com/spf13/cobra"
+	"github.com/tiernacity/ratchet/internal/baseline"
+	"github.com/tiernacity/ratchet/internal/cache"
 	"github.com/tiernacity/ratchet/internal/config"
+	"github.com/tiernacity/ratchet/internal/count"
+	"github.com/tiernacity/ratchet/internal/git"
+	"github.com/tiernacity/ratchet/internal/parser"
 	"github.com/tiernacity/ratchet/internal/ratchet"
+	"github.com/tiernacity/ratchet/internal/report"
 )
 
 var (
@@ -21,20 +32,75 @@ var (
 	pre  string
 	post string
 
+	// Default limit on how long each step may run
+	timeout string
+
+	// Item and key metrics
+	items       bool
+	normalize   string
+	keys        bool
+	newKeys     string
+	deletedKeys string
+
+	// SARIF metrics
+	sarifFile   string
+	sarifOutput string
+
+	// Coverage metrics
+	coverageFile string
+	changedLines bool
+	threshold    string
+
+	// Built-in count
+	countPatterns []string
+	countInclude  []string
+	countExclude  []string
+
+	// When the base cannot be measured
+	baseFailure string
+	baseDefault string
+	baseHelpers []string
+
+	// Allowance for comparisons
+	tolerance string
+
 	// Config
-	configFile string
-	configStr  string
+	configFile    string
+	configStr     string
+	trustedConfig string
+
+	// Cache
+	cacheDir   string
+	noCache    bool
+	clearCache bool
+
+	// Git notes
+	notesRead   bool
+	notesWrite  bool
+	notesRemote string
+
+	// Machine-readable output
+	outputFormat string
+	outputFile   string
+	junitFile    string
+
+	// Committed baseline used instead of a base ref
+	baselineFile  string
+	tightenFlag   bool
+	tightenCommit bool
 
 	// Other flags
-	verbose bool
-	version = "0.1.0"
+	verbose   bool
+	parallel  bool
+	mergeBase bool
+	version   = "0.1.0"
 )
 
 var rootCmd = &cobra.Command{
 	Use:           "ratchet [flags] <metric command>",
 	Short:         "A software ratchet tool that ensures metrics only improve",
 	Long:          `Ratchet compares a metric output between your current branch/HEAD and a base branch and applies the test that you specify`,
-	Args:          cobra.MaximumNArgs(1),
+	Args:          usageArgs(cobra.MaximumNArgs(1)),
 	SilenceUsage:  true,
 	SilenceErrors: true,
 	RunE:          runRatchet,
@@ -65,7 +131,25 @@ func runRatchet(cmd *cobra.Command, args []string) error {
 	}
 
 	if cliComparisons > 1 {
-		return fmt.Errorf("only one comparison operator can be specified")
+		return &usageError{err: fmt.Errorf("only one comparison operator can be specified"), showUsage: true}
+	}
+
+	var defaultBase *float64
+	if baseDefault != "" {
+		v, err := strconv.ParseFloat(baseDefault, 64)
+		if err != nil {
+			return usageErrorf("invalid base default '%s': expected a number", baseDefault)
+		}
+		defaultBase = &v
+	}
+
+	var fixedThreshold *float64
+	if threshold != "" {
+		v, err := strconv.ParseFloat(threshold, 64)
+		if err != nil {
+			return usageErrorf("invalid threshold '%s': expected a number", threshold)
+		}
+		fixedThreshold = &v
 	}
 
 	// Determine metric source
@@ -75,70 +159,488 @@ func runRatchet(cmd *cobra.Command, args []string) error {
 	}
 
 	// Load configuration
+	cfg, err := loadConfig()
+	if err != nil {
+		return err
+	}
+
+	// Merge with command-line flags (flags take precedence)
+	cfg.MergeWithFlags(config.Flags{
+		Metric:        metric,
+		Pre:           pre,
+		Post:          post,
+		LT:            lessThan,
+		LE:            lessEqual,
+		EQ:            equalTo,
+		GE:            greaterEqual,
+		GT:            greaterThan,
+		Verbose:       verbose,
+		Parallel:      parallel,
+		MergeBase:     mergeBase,
+		BaselineFile:  baselineFile,
+		Tighten:       tightenFlag,
+		TightenCommit: tightenCommit,
+		CacheDir:      cacheDir,
+		NoCache:       noCache,
+		NotesRead:     notesRead,
+		NotesWrite:    notesWrite,
+		NotesRemote:   notesRemote,
+		Tolerance:     tolerance,
+		Timeout:       timeout,
+		Items:         items,
+		Normalize:     normalize,
+		Keys:          keys,
+		NewKeys:       newKeys,
+		DeletedKeys:   deletedKeys,
+		SARIF:         sarifFile,
+		SARIFOutput:   sarifOutput,
+		Coverage:      coverageFile,
+		ChangedLines:  changedLines,
+		Threshold:     fixedThreshold,
+		Count:         countPatterns,
+		Include:       countInclude,
+		Exclude:       countExclude,
+		BaseFailure:   baseFailure,
+		BaseDefault:   defaultBase,
+		BaseHelpers:   baseHelpers,
+		Output:        outputFormat,
+		OutputFile:    outputFile,
+		JUnit:         junitFile,
+	})
+
+	// Set up the base result cache
+	resultCache, err := openCache(cfg, clearCache)
+	if err != nil {
+		return err
+	}
+
+	if clearCache {
+		if err := resultCache.Clear(); err != nil {
+			return err
+		}
+		fmt.Printf("Cleared cache %s\n", resultCache.Dir())
+		return nil
+	}
+
+	// Validate configuration
+	if err := validateConfig(cfg); err != nil {
+		return err
+	}
+
+	// Build the metrics to evaluate from config
+	metrics, err := buildMetrics(cfg)
+	if err != nil {
+		return err
+	}
+
+	// A baseline file replaces every base ref; the comparison only gives the direction
+	if cfg.BaselineFile != "" {
+		for i := range metrics {
+			if metrics[i].ComparisonType != ratchet.NoComparison {
+				metrics[i].BaseRef = cfg.BaselineFile
+			}
+		}
+	}
+
+	// A trusted config also means a trusted baseline
+	if trustedConfig != "" && cfg.BaselineFile != "" {
+		if err := checkTrustedBaseline(trustedConfig, cfg.BaselineFile); err != nil {
+			return err
+		}
+	}
+
+	// Outside baseline mode tightening rewrites the thresholds in the config
+	// file, which must be the one on disk that they were read from
+	var thresholdFile string
+	if (cfg.Tighten || cfg.TightenCommit) && cfg.BaselineFile == "" {
+		switch {
+		case configStr != "" || trustedConfig != "":
+			return usageErrorf("tightening a threshold requires it to be read from a config file, not --config or --trusted-config")
+		case configFile != "":
+			thresholdFile = configFile
+		default:
+			thresholdFile = config.DefaultFile
+		}
+		if _, err := os.Stat(thresholdFile); err != nil {
+			return usageErrorf("tightening a threshold requires it to be set in %s", thresholdFile)
+		}
+	}
+
+	opts := ratchet.Options{
+		Metrics:    metrics,
+		Verbose:    cfg.Verbose,
+		Parallel:   cfg.Parallel,
+		MergeBase:  cfg.MergeBase,
+		Baseline:   cfg.BaselineFile,
+		TrustedRef: trustedConfig,
+		Tighten: ratchet.TightenOptions{
+			Enabled:    cfg.Tighten || cfg.TightenCommit,
+			Commit:     cfg.TightenCommit,
+			ConfigFile: thresholdFile,
+		},
+		Cache: resultCache,
+		Notes: ratchet.NotesOptions{
+			Read:   cfg.Notes.Read,
+			Write:  cfg.Notes.Write,
+			Remote: cfg.Notes.Remote,
+		},
+	}
+
+	// JSON replaces the human-readable output unless it is written to a file
+	format := cfg.Output
+	if format == "" {
+		format = "text"
+	}
+	if format != "text" && format != "json" {
+		return usageErrorf("invalid output format '%s': expected text or json", format)
+	}
+	if format == "json" && cfg.OutputFile == "" {
+		opts.Output = io.Discard
+	}
+
+	// In GitHub Actions, workflow commands go to stderr if stdout carries JSON
+	github := report.GitHubActions()
+	var commands io.Writer = os.Stdout
+	if opts.Output == io.Discard {
+		commands = os.Stderr
+	}
+	if github {
+		opts.OnEvaluated = func(res ratchet.Result) {
+			report.WriteGitHubGroups(commands, res)
+		}
+	}
+
+	results, runErr := ratchet.Run(opts)
+
+	if github {
+		report.WriteGitHubAnnotations(commands, results, runErr)
+		if err := appendGitHubFile("GITHUB_STEP_SUMMARY", func(w io.Writer) error {
+			return report.WriteGitHubSummary(w, results, runErr)
+		}); err != nil {
+			return err
+		}
+		if err := appendGitHubFile("GITHUB_OUTPUT", func(w io.Writer) error {
+			return report.WriteGitHubOutputs(w, results, runErr)
+		}); err != nil {
+			return err
+		}
+	}
+
+	if format == "json" || cfg.OutputFile != "" {
+		if err := writeJSON(cfg.OutputFile, results, runErr); err != nil {
+			return err
+		}
+	}
+	if cfg.JUnit != "" {
+		var buf bytes.Buffer
+		if err := report.WriteJUnit(&buf, results, runErr); err != nil {
+			return err
+		}
+		if err := os.WriteFile(cfg.JUnit, buf.Bytes(), 0o644); err != nil {
+			return fmt.Errorf("failed to write JUnit report %s: %w", cfg.JUnit, err)
+		}
+	}
+	return runErr
+}
+
+// writeJSON writes the JSON results to path, or to stdout if path is empty
+func writeJSON(path string, results []ratchet.Result, runErr error) error {
+	if path == "" {
+		return report.WriteJSON(os.Stdout, results, runErr)
+	}
+
+	var buf bytes.Buffer
+	if err := report.WriteJSON(&buf, results, runErr); err != nil {
+		return err
+	}
+	if err := os.WriteFile(path, buf.Bytes(), 0o644); err != nil {
+		return fmt.Errorf("failed to write output file %s: %w", path, err)
+	}
+	return nil
+}
+
+// loadConfig loads the config from --config, --config-file or the default
+// location, in that order of preference, taking the file from the trusted
+// ref if one is given
+func loadConfig() (*config.Config, error) {
+	if trustedConfig != "" {
+		return loadTrustedConfig(trustedConfig)
+	}
 	var cfg *config.Config
 	var err error
-
-	if configStr != "" {
+	switch {
+	case configStr != "":
 		// Load from config string (YAML or JSON)
 		cfg, err = config.LoadFromConfigString(configStr)
-		if err != nil {
-			return err
-		}
-	} else if configFile != "" {
+	case configFile != "":
 		// Load from specified file
 		cfg, err = config.LoadFromFile(configFile)
-		if err != nil {
-			return err
-		}
-	} else {
+	default:
 		// Try to load default config
 		cfg, err = config.LoadDefault()
-		if err != nil {
-			return err
+	}
+	if err != nil {
+		return nil, &usageError{err: err}
+	}
+	return cfg, nil
+}
+
+// validateConfig checks the merged config, showing usage if nothing was
+// given to measure
+func validateConfig(cfg *config.Config) error {
+	if err := cfg.Validate(); err != nil {
+		return &usageError{err: err, showUsage: errors.Is(err, config.ErrNoMetric)}
+	}
+	return nil
+}
+
+// loadTrustedConfig loads the config file as committed at ref, so that a
+// branch cannot loosen its own checks by editing it, and reports how the
+// working copy's config differs. A file missing at ref gives an empty config.
+// A --config string comes from the workflow rather than the branch, so it
+// overrides the trusted file as flags do.
+func loadTrustedConfig(ref string) (*config.Config, error) {
+	path := configFile
+	if path == "" {
+		path = config.DefaultFile
+	}
+
+	trusted := &config.Config{}
+	data, found, err := git.ShowFile(ref, path)
+	if err != nil {
+		return nil, &ratchet.GitError{Err: err}
+	}
+	if found {
+		if trusted, err = config.LoadFromData(ref+":"+path, data); err != nil {
+			return nil, &usageError{err: err}
 		}
 	}
 
-	// Merge with command-line flags (flags take precedence)
-	cfg.MergeWithFlags(metric, pre, post, lessThan, lessEqual, equalTo, greaterEqual, greaterThan, verbose)
+	local := &config.Config{}
+	var changes []string
+	if _, statErr := os.Stat(path); statErr == nil {
+		if local, err = config.LoadFromFile(path); err != nil {
+			changes = append(changes, err.Error())
+		}
+	}
+	if local != nil {
+		changes = append(changes, config.Changes(trusted, local)...)
+	}
+	warnUntrusted("ratchet config changed", fmt.Sprintf("%s differs from %s; using the config from %s", path, ref, ref), changes)
 
-	// Validate configuration
-	if err := cfg.Validate(); err != nil {
+	if configStr != "" {
+		inline, err := config.LoadFromConfigString(configStr)
+		if err != nil {
+			return nil, &usageError{err: err}
+		}
+		trusted.Overlay(inline)
+	}
+	return trusted, nil
+}
+
+// checkTrustedBaseline reports how the working copy's baseline file differs
+// from the one committed at ref, which is the one compared against
+func checkTrustedBaseline(ref string, path string) error {
+	data, found, err := git.ShowFile(ref, path)
+	if err != nil {
+		return &ratchet.GitError{Err: err}
+	}
+	if !found {
+		// Run reports the missing file
+		return nil
+	}
+	trusted, err := baseline.Parse(ref+":"+path, data)
+	if err != nil {
 		return err
 	}
 
-	// Get comparison info from config
-	compType, baseRef := cfg.GetComparisonInfo()
+	var changes []string
+	if local, exists, err := baseline.Load(path); err != nil {
+		changes = []string{err.Error()}
+	} else if exists {
+		changes = baseline.Changes(trusted, local)
+	}
+	warnUntrusted("ratchet baseline changed", fmt.Sprintf("%s differs from %s; using the baseline from %s", path, ref, ref), changes)
+	return nil
+}
+
+// warnUntrusted reports changes made in the working copy to a file that is
+// read from a trusted ref instead, if there are any
+func warnUntrusted(heading string, title string, changes []string) {
+	if len(changes) == 0 {
+		return
+	}
+	if report.GitHubActions() {
+		report.WriteGitHubWarning(os.Stderr, heading, title+"\n"+strings.Join(changes, "\n"))
+		return
+	}
+	fmt.Fprintf(os.Stderr, "Warning: %s\n", title)
+	for _, change := range changes {
+		fmt.Fprintf(os.Stderr, "  %s\n", change)
+	}
+}
+
+// openCache returns the result cache configured by cfg, or nil if it is
+// disabled. If the cache directory cannot be determined the cache is disabled
+// with a warning, unless required.
+func openCache(cfg *config.Config, required bool) (*cache.Cache, error) {
+	if cfg.NoCache && !required {
+		return nil, nil
+	}
+
+	dir := cfg.CacheDir
+	if dir == "" {
+		var err error
+		dir, err = cache.DefaultDir()
+		if err != nil {
+			if required {
+				return nil, err
+			}
+			fmt.Fprintf(os.Stderr, "Warning: result cache disabled: %v\n", err)
+			return nil, nil
+		}
+	}
+	return cache.New(dir), nil
+}
+
+// buildMetrics converts the metrics in cfg into the form ratchet evaluates
+func buildMetrics(cfg *config.Config) ([]ratchet.Metric, error) {
+	var metrics []ratchet.Metric
+	for _, m := range cfg.ResolveMetrics() {
+		compType, baseRef := m.GetComparisonInfo()
+		tol, err := ratchet.ParseTolerance(m.Tolerance)
+		if err != nil {
+			return nil, &usageError{err: err}
+		}
+		timeouts, err := ratchet.ParseTimeouts(m.Timeout, m.PreTimeout, m.MetricTimeout, m.PostTimeout)
+		if err != nil {
+			return nil, &usageError{err: err}
+		}
+		normalizer, err := parser.NewNormalizer(m.Normalize)
+		if err != nil {
+			return nil, &usageError{err: err}
+		}
+		command := m.Metric
+		var counter *count.Counter
+		if m.Count != nil {
+			if counter, err = count.New(m.Count.Patterns, m.Count.Include, m.Count.Exclude); err != nil {
+				return nil, &usageError{err: err}
+			}
+			command = counter.String()
+		}
+		policy, err := ratchet.ParseBaseFailure(m.BaseFailure)
+		if err != nil {
+			return nil, &usageError{err: err}
+		}
+		var fallback float64
+		if m.BaseDefault != nil {
+			fallback = *m.BaseDefault
+		}
+		extractMode, extractExpr := m.Extract.GetExtractInfo()
+		extractor, err := parser.NewExtractor(parseExtractMode(extractMode), extractExpr)
+		if err != nil {
+			return nil, &usageError{err: err}
+		}
+		metrics = append(metrics, ratchet.Metric{
+			Name:           m.Name,
+			Command:        command,
+			BaseRef:        baseRef,
+			ComparisonType: parseComparisonType(compType),
+			Pre:            m.Pre,
+			Post:           m.Post,
+			Tolerance:      tol,
+			Timeouts:       timeouts,
+			Extractor:      extractor,
+			Items:          m.Items,
+			Normalize:      normalizer,
+			Keys: ratchet.KeyOptions{
+				Enabled:     m.Keys,
+				AllowNew:    m.NewKeys == "allow",
+				FailDeleted: m.DeletedKeys == "fail",
+			},
+			SARIF:        m.SARIF,
+			SARIFOutput:  m.SARIFOutput,
+			Coverage:     m.Coverage,
+			ChangedLines: m.ChangedLines,
+			Threshold:    m.Threshold,
+			Count:        counter,
+			BaseFailure:  policy,
+			BaseDefault:  fallback,
+			BaseHelpers:  m.BaseHelpers,
+		})
+	}
+	return metrics, nil
+}
+
+// parseExtractMode maps a config extract mode string to its enum
+func parseExtractMode(mode string) parser.Mode {
+	switch mode {
+	case "regex":
+		return parser.Regex
+	case "json":
+		return parser.JSONPath
+	case "last":
+		return parser.LastNumber
+	case "key":
+		return parser.KeyValue
+	default:
+		return parser.Whole
+	}
+}
+
+// appendGitHubFile appends what write produces to the file named by the given
+// GitHub Actions environment variable, doing nothing if it is unset
+func appendGitHubFile(env string, write func(io.Writer) error) error {
+	path := os.Getenv(env)
+	if path == "" {
+		return nil
+	}
+
+	var buf bytes.Buffer
+	if err := write(&buf); err != nil {
+		return err
+	}
+	f, err := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o644)
+	if err != nil {
+		return fmt.Errorf("failed to open %s: %w", env, err)
+	}
+	if _, err := f.Write(buf.Bytes()); err != nil {
+		_ = f.Close()
+		return fmt.Errorf("failed to write %s: %w", env, err)
+	}
+	if err := f.Close(); err != nil {
+		return fmt.Errorf("failed to write %s: %w", env, err)
+	}
+	return nil
+}
 
-	// Map comparison type string to enum
-	var comparisonType ratchet.ComparisonType
+// parseComparisonType maps a config comparison type string to its enum
+func parseComparisonType(compType string) ratchet.ComparisonType {
 	switch compType {
 	case "lt":
-		comparisonType = ratchet.LessThan
+		return ratchet.LessThan
 	case "le":
-		comparisonType = ratchet.LessEqual
+		return ratchet.LessEqual
 	case "eq":
-		comparisonType = ratchet.Equal
+		return ratchet.Equal
 	case "ge":
-		comparisonType = ratchet.GreaterEqual
+		return ratchet.GreaterEqual
 	case "gt":
-		comparisonType = ratchet.GreaterThan
+		return ratchet.GreaterThan
 	default:
-		comparisonType = ratchet.NoComparison
-	}
-
-	opts := ratchet.Options{
-		Metric:         cfg.Metric,
-		BaseRef:        baseRef,
-		ComparisonType: comparisonType,
-		Pre:            cfg.Pre,
-		Post:           cfg.Post,
-		Verbose:        cfg.Verbose,
+		return ratchet.NoComparison
 	}
-
-	return ratchet.Run(opts)
 }
 
 func init() {
+	// Only subcommands that ratchet defines are offered
+	rootCmd.CompletionOptions.DisableDefaultCmd = true
+
+	// Bad flags are usage errors for every command
+	rootCmd.SetFlagErrorFunc(func(cmd *cobra.Command, err error) error {
+		return &usageError{err: err, showUsage: true}
+	})
+
 	// Comparison flags
 	rootCmd.Flags().StringVar(&lessThan, "less-than", "", "test that HEAD metric < base branch metric")
 	rootCmd.Flags().StringVar(&lessThan, "lt", "", "test that HEAD metric < base branch metric")
@@ -154,17 +656,56 @@ func init() {
 	rootCmd.Flags().StringVar(&greaterEqual, "ge", "", "test that HEAD metric >= base branch metric")
 	rootCmd.Flags().StringVar(&greaterThan, "greater-than", "", "test that HEAD metric > base branch metric")
 	rootCmd.Flags().StringVar(&greaterThan, "gt", "", "test that HEAD metric > base branch metric")
+	rootCmd.Flags().StringVar(&tolerance, "tolerance", "", "allow HEAD to be worse by an absolute amount (0.5) or percentage (1%)")
 
 	// Setup/teardown flags
 	rootCmd.Flags().StringVar(&pre, "pre", "", "command to run before metric command")
-	rootCmd.Flags().StringVar(&post, "post", "", "command to run after metric command")
+	rootCmd.Flags().StringVar(&post, "post", "", "command to run after metric command, even if it fails")
+	rootCmd.Flags().StringVar(&timeout, "timeout", "", "kill any pre, metric or post command that runs longer than this (e.g. 10m)")
+	rootCmd.Flags().BoolVar(&items, "items", false, "treat each output line as an item, count them and report those added or removed")
+	rootCmd.Flags().StringVar(&normalize, "normalize", "", "with --items, strip line-numbers or a regex from items before comparing")
+	rootCmd.Flags().BoolVar(&keys, "keys", false, "read key<TAB>number lines and apply the comparison to each key")
+	rootCmd.Flags().StringVar(&newKeys, "new-keys", "", "with --keys, whether keys not in the base must be zero (default) or are allowed: zero or allow")
+	rootCmd.Flags().StringVar(&deletedKeys, "deleted-keys", "", "with --keys, whether keys missing from HEAD are allowed (default) or fail: allow or fail")
+	rootCmd.Flags().StringVar(&sarifFile, "sarif", "", "read the SARIF log the metric command writes to this path (- for stdout) and fail only on new results")
+	rootCmd.Flags().StringVar(&sarifOutput, "sarif-output", "", "with --sarif, write HEAD's SARIF log holding only the new results to this path")
+	rootCmd.Flags().StringVar(&coverageFile, "coverage", "", "read the Go cover profile or LCOV trace the metric command writes to this path (- for stdout)")
+	rootCmd.Flags().BoolVar(&changedLines, "changed-lines", false, "with --coverage, measure HEAD on the lines changed since the base ref only")
+	rootCmd.Flags().StringVar(&threshold, "threshold", "", "compare HEAD against this value instead of the base's")
+	rootCmd.Flags().StringArrayVar(&countPatterns, "count", nil, "count lines matching this regex in files git lists, instead of running a metric command (repeatable)")
+	rootCmd.Flags().StringArrayVar(&countInclude, "include", nil, "with --count, only files matching this glob (repeatable)")
+	rootCmd.Flags().StringArrayVar(&countExclude, "exclude", nil, "with --count, skip files matching this glob (repeatable)")
+	rootCmd.Flags().StringVar(&baseFailure, "base-failure", "", "when the metric cannot be measured on the base: fail (default), warn or default")
+	rootCmd.Flags().StringVar(&baseDefault, "base-default", "", "with --base-failure default, the value to compare against")
+	rootCmd.Flags().StringArrayVar(&baseHelpers, "base-helper", nil, "copy this file from the working copy into the base before measuring it (repeatable)")
 
 	// Config flags
 	rootCmd.Flags().StringVar(&configFile, "config-file", "", "path to config file (YAML or JSON)")
+	rootCmd.Flags().StringVar(&trustedConfig, "trusted-config", "", "read the config and baseline files as committed at this ref, ignoring the working copy's")
 	rootCmd.Flags().StringVar(&configStr, "config", "", "config string (YAML or JSON)")
 
+	// Cache flags
+	rootCmd.Flags().StringVar(&cacheDir, "cache-dir", "", "directory for cached base results (default: user cache dir)")
+	rootCmd.Flags().BoolVar(&noCache, "no-cache", false, "always measure the base instead of using cached results")
+	rootCmd.Flags().BoolVar(&clearCache, "clear-cache", false, "remove all cached base results and exit")
+
+	// Git notes flags
+	rootCmd.Flags().BoolVar(&notesRead, "notes-read", false, "use the base value recorded in refs/notes/ratchet when present")
+	rootCmd.Flags().BoolVar(&notesWrite, "notes-write", false, "record measured values in refs/notes/ratchet")
+	rootCmd.Flags().StringVar(&notesRemote, "notes-remote", "", "remote to fetch notes from and push notes to")
+
+	// Output flags
+	rootCmd.Flags().StringVar(&outputFormat, "output", "", "output format: text (default) or json")
+	rootCmd.Flags().StringVar(&outputFile, "output-file", "", "write JSON results to this file")
+	rootCmd.Flags().StringVar(&junitFile, "junit", "", "write a JUnit XML report to this file")
+
 	// Other flags
 	rootCmd.Flags().BoolVarP(&verbose, "verbose", "v", false, "show detailed output including both values")
+	rootCmd.Flags().BoolVar(&parallel, "parallel", false, "measure base and HEAD at the same time")
+	rootCmd.Flags().BoolVar(&mergeBase, "merge-base", false, "measure the merge-base of HEAD and the base ref instead of its tip")
+	rootCmd.Flags().StringVar(&baselineFile, "baseline-file", "", "compare against values in this committed file instead of a base ref")
+	rootCmd.Flags().BoolVar(&tightenFlag, "tighten", false, "after success, record improved values in the baseline file or config thresholds")
+	rootCmd.Flags().BoolVar(&tightenCommit, "tighten-commit", false, "tighten, then commit the rewritten file")
 	rootCmd.Flags().Bool("version", false, "show version information")
 
 	// Custom usage template to group comparison operators
@@ -193,14 +734,47 @@ Comparison operators (choose one):
       --equal-to, --eq <base>        test that HEAD metric == base branch metric
       --greater-equal, --ge <base>   test that HEAD metric >= base branch metric
       --greater-than, --gt <base>    test that HEAD metric > base branch metric
+      --tolerance <amount>           allow HEAD to be worse by an absolute amount (0.5) or percentage (1%)
 
 Other flags:
   -h, --help                   help for ratchet
       --pre <command>          Command to run before metric command
-      --post <command>         Command to run after metric command
+      --post <command>         Command to run after metric command, even if it fails
+      --timeout <duration>     Kill any pre, metric or post command that runs longer than this (e.g. 10m)
+      --items                  Treat each output line as an item, count them and report those added or removed
+      --normalize <how>        With --items, strip line-numbers or a regex from items before comparing
+      --keys                   Read key<TAB>number lines and apply the comparison to each key
+      --new-keys <policy>      With --keys, keys not in the base must be zero (default) or are allowed: zero or allow
+      --deleted-keys <policy>  With --keys, keys missing from HEAD are allowed (default) or fail: allow or fail
+      --sarif <path>           Read the SARIF log the metric command writes to this path (- for stdout) and fail only on new results
+      --sarif-output <path>    With --sarif, write HEAD's SARIF log holding only the new results to this path
+      --coverage <path>        Read the Go cover profile or LCOV trace the metric command writes to this path (- for stdout)
+      --changed-lines          With --coverage, measure HEAD on the lines changed since the base ref only
+      --threshold <value>      Compare HEAD against this value instead of the base's
+      --count <regex>          Count lines matching this regex in files git lists, instead of running a metric command (repeatable)
+      --include <glob>         With --count, only files matching this glob (repeatable)
+      --exclude <glob>         With --count, skip files matching this glob (repeatable)
+      --base-failure <policy>  When the metric cannot be measured on the base: fail (default), warn or default
+      --base-default <value>   With --base-failure default, the value to compare against
+      --base-helper <path>     Copy this file from the working copy into the base before measuring it (repeatable)
       --config-file string     Path to config file (YAML or JSON)
       --config string          Config string (YAML or JSON)
+      --trusted-config <ref>   Read the config and baseline files as committed at this ref, ignoring and reporting changes to the working copy's
+      --cache-dir <dir>        Directory for cached base results (default: user cache dir)
+      --no-cache               Always measure the base instead of using cached results
+      --clear-cache            Remove all cached base results and exit
+      --notes-read             Use the base value recorded in refs/notes/ratchet when present
+      --notes-write            Record measured values in refs/notes/ratchet
+      --notes-remote <remote>  Remote to fetch notes from and push notes to
+      --output <format>        Output format: text (default) or json
+      --output-file <path>     Write JSON results to this file
+      --junit <path>           Write a JUnit XML report to this file
   -v, --verbose                Show detailed output including both values
+      --parallel               Measure base and HEAD at the same time
+      --merge-base             Measure the merge-base of HEAD and the base ref instead of its tip
+      --baseline-file <path>   Compare against values in this committed file instead of a base ref
+      --tighten                After success, record improved values in the baseline file or config thresholds
+      --tighten-commit         Tighten, then commit the rewritten file
       --version                Show version information{{end}}{{if .HasAvailableInheritedFlags}}
 
 Global Flags:
@@ -214,24 +788,20 @@ Use "{{.CommandPath}} [command] --help" for more information about a command.{{e
 }
 
 func main() {
-	if err := rootCmd.Execute(); err != nil {
-		// For config validation errors, show usage
-		if err.Error() == "a metric command is required" || err.Error() == "only one comparison operator can be specified" {
+	if cmd, err := rootCmd.ExecuteC(); err != nil {
+		// Failures reported along with the results are not repeated
+		var reported *ratchet.ReportedError
+		if !errors.As(err, &reported) {
 			fmt.Fprintln(os.Stderr, "Error:", err)
-			if err := rootCmd.Usage(); err != nil {
-				// If we can't print usage, just continue with exit
-				fmt.Fprintf(os.Stderr, "Warning: failed to print usage: %v\n", err)
-			}
-			os.Exit(2)
 		}
 
-		// Exit code 1 for metric test failures
-		if err.Error() == "metric test failed" {
-			os.Exit(1)
+		// Some usage errors are best explained by the usage of the command that was run
+		var usage *usageError
+		if errors.As(err, &usage) && usage.showUsage {
+			if err := cmd.Usage(); err != nil {
+				fmt.Fprintf(os.Stderr, "Warning: failed to print usage: %v\n", err)
+			}
 		}
-
-		// Exit code 2 for other errors (no usage)
-		fmt.Fprintln(os.Stderr, "Error:", err)
-		os.Exit(2)
+		os.Exit(exitCode(err))
 	}
 }
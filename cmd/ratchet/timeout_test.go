@@ -0,0 +1,71 @@
+package main
+
+import (
+	"os"
+	"os/exec"
+	"testing"
+
+	"github.com/spf13/pflag"
+)
+
+// chdirRepo makes the current directory a new git repository with one commit
+// on main for the rest of the test
+func chdirRepo(t *testing.T) {
+	t.Helper()
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git not available")
+	}
+	t.Setenv("GIT_AUTHOR_NAME", "test")
+	t.Setenv("GIT_AUTHOR_EMAIL", "test@example.com")
+	t.Setenv("GIT_COMMITTER_NAME", "test")
+	t.Setenv("GIT_COMMITTER_EMAIL", "test@example.com")
+
+	repo := t.TempDir()
+	wd, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chdir(repo); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { os.Chdir(wd) })
+
+	for _, args := range [][]string{
+		{"init", "-q", "-b", "main"},
+		{"commit", "-q", "--allow-empty", "-m", "init"},
+	} {
+		if out, err := exec.Command("git", args...).CombinedOutput(); err != nil {
+			t.Fatalf("git %v: %v\n%s", args, err, out)
+		}
+	}
+}
+
+// execute runs the root command with args, resetting its flags afterwards so
+// that they do not carry over into other tests
+func execute(t *testing.T, args ...string) error {
+	t.Helper()
+	t.Cleanup(func() {
+		rootCmd.Flags().VisitAll(func(f *pflag.Flag) {
+			if slice, ok := f.Value.(pflag.SliceValue); ok {
+				slice.Replace(nil)
+			} else {
+				f.Value.Set(f.DefValue)
+			}
+			f.Changed = false
+		})
+		rootCmd.SetArgs(nil)
+	})
+	rootCmd.SetArgs(args)
+	return rootCmd.Execute()
+}
+
+func TestTimeoutExitCode(t *testing.T) {
+	chdirRepo(t)
+	err := execute(t, "--timeout", "200ms", "--le", "main", "sleep 30; echo 1")
+	if err == nil {
+		t.Fatal("expected the run to fail")
+	}
+	if code := exitCode(err); code != exitStep {
+		t.Errorf("exit code %d for %v, want %d", code, err, exitStep)
+	}
+}
@@ -0,0 +1,152 @@
+package main
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/spf13/cobra"
+	"github.com/tiernacity/ratchet/internal/baseline"
+	"github.com/tiernacity/ratchet/internal/config"
+	"github.com/tiernacity/ratchet/internal/ratchet"
+)
+
+var (
+	// Baseline update settings
+	baselineDirection string
+	baselineName      string
+	baselineForce     bool
+)
+
+var updateBaselineCmd = &cobra.Command{
+	Use:   "update-baseline [metric command]",
+	Short: "Record improved metric values in the baseline file",
+	Long: `Measure each metric in the working copy and record its value in the baseline
+file if the file has none yet or the value is better than the one recorded.
+Worse values are left alone unless --force is given, so committing the file
+ratchets the baseline forwards.
+
+Which values are better follows each metric's comparison operator, or
+--direction for a metric given on the command line.`,
+	Example: `  ratchet update-baseline
+  ratchet update-baseline --direction lower 'grep -r TODO . | wc -l'`,
+	Args:          usageArgs(cobra.MaximumNArgs(1)),
+	SilenceUsage:  true,
+	SilenceErrors: true,
+	RunE:          runUpdateBaseline,
+}
+
+func runUpdateBaseline(cmd *cobra.Command, args []string) error {
+	var metric string
+	if len(args) > 0 {
+		metric = args[0]
+	}
+
+	var ct ratchet.ComparisonType
+	switch baselineDirection {
+	case "":
+		ct = ratchet.NoComparison
+	case "lower":
+		ct = ratchet.LessEqual
+	case "higher":
+		ct = ratchet.GreaterEqual
+	default:
+		return usageErrorf("--direction must be lower or higher, whichever is better for the metric")
+	}
+
+	cfg, err := loadConfig()
+	if err != nil {
+		return err
+	}
+	cfg.MergeWithFlags(config.Flags{
+		Metric:       metric,
+		Pre:          pre,
+		Post:         post,
+		Verbose:      verbose,
+		BaselineFile: baselineFile,
+		Timeout:      timeout,
+	})
+	if err := validateConfig(cfg); err != nil {
+		return err
+	}
+
+	metrics, err := buildMetrics(cfg)
+	if err != nil {
+		return err
+	}
+	if baselineName != "" {
+		m, err := selectMetric(metrics, baselineName)
+		if err != nil {
+			return err
+		}
+		metrics = []ratchet.Metric{m}
+	}
+	if ct != ratchet.NoComparison {
+		for i := range metrics {
+			metrics[i].ComparisonType = ct
+		}
+	}
+
+	path := cfg.BaselineFile
+	if path == "" {
+		path = baseline.DefaultPath
+	}
+	opts := ratchet.BaselineOptions{
+		Metrics: metrics,
+		Path:    path,
+		Force:   baselineForce,
+	}
+	if cfg.Verbose {
+		opts.Progress = os.Stderr
+	}
+
+	updates, err := ratchet.UpdateBaseline(opts)
+	changed := false
+	for _, u := range updates {
+		label := u.Metric.Name
+		if label == "" {
+			label = u.Metric.Command
+		}
+		switch {
+		case u.Err != nil:
+			fmt.Fprintf(os.Stderr, "%s: %v\n", label, u.Err)
+		case !u.Recorded:
+			fmt.Printf("%s: recorded %g\n", label, u.Value)
+		case u.Updated:
+			fmt.Printf("%s: %g -> %g\n", label, u.Previous, u.Value)
+		case u.Value == u.Previous:
+			fmt.Printf("%s: unchanged at %g\n", label, u.Value)
+		default:
+			fmt.Printf("%s: kept %g (measured %g is not better)\n", label, u.Previous, u.Value)
+		}
+		changed = changed || u.Updated
+	}
+	if err != nil {
+		return err
+	}
+
+	if changed {
+		fmt.Printf("Updated %s\n", path)
+	} else {
+		fmt.Printf("%s is up to date\n", path)
+	}
+	return nil
+}
+
+func init() {
+	updateBaselineCmd.Flags().StringVar(&baselineFile, "baseline-file", "", "baseline file to update (default: "+baseline.DefaultPath+")")
+	updateBaselineCmd.Flags().StringVar(&baselineDirection, "direction", "", "which values are better: lower or higher (default: from each metric's comparison)")
+	updateBaselineCmd.Flags().StringVar(&baselineName, "name", "", "only update the named metric")
+	updateBaselineCmd.Flags().BoolVar(&baselineForce, "force", false, "record the measured values even if they are worse")
+
+	updateBaselineCmd.Flags().StringVar(&pre, "pre", "", "command to run before metric command")
+	updateBaselineCmd.Flags().StringVar(&post, "post", "", "command to run after metric command, even if it fails")
+	updateBaselineCmd.Flags().StringVar(&timeout, "timeout", "", "kill any pre, metric or post command that runs longer than this (e.g. 10m)")
+	updateBaselineCmd.Flags().StringVar(&configFile, "config-file", "", "path to config file (YAML or JSON)")
+	updateBaselineCmd.Flags().StringVar(&configStr, "config", "", "config string (YAML or JSON)")
+	updateBaselineCmd.Flags().BoolVarP(&verbose, "verbose", "v", false, "show progress of each metric on stderr")
+
+	// The root command's usage lists its own flags, so use cobra's default here
+	updateBaselineCmd.SetUsageTemplate((&cobra.Command{}).UsageTemplate())
+
+	rootCmd.AddCommand(updateBaselineCmd)
+}
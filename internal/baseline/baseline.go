@@ -0,0 +1,98 @@
+package baseline
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"sort"
+)
+
+// DefaultPath is where the baseline file is kept unless configured otherwise
+const DefaultPath = ".ratchet-baseline.json"
+
+// version is incremented whenever the file format changes incompatibly
+const version = 1
+
+// File holds the committed baseline value of each metric, keyed by metric name
+// (or command, for an unnamed metric)
+type File struct {
+	Version int                `json:"version"`
+	Metrics map[string]float64 `json:"metrics"`
+}
+
+// Load reads the baseline file at path. A missing file yields an empty
+// baseline with exists set to false.
+func Load(path string) (f *File, exists bool, err error) {
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return &File{Version: version, Metrics: make(map[string]float64)}, false, nil
+	}
+	if err != nil {
+		return nil, false, fmt.Errorf("failed to read baseline file %s: %w", path, err)
+	}
+	if f, err = Parse(path, data); err != nil {
+		return nil, false, err
+	}
+	return f, true, nil
+}
+
+// Parse reads a baseline file read from path, which may name a file in a
+// commit rather than on disk
+func Parse(path string, data []byte) (*File, error) {
+	f := &File{}
+	if err := json.Unmarshal(data, f); err != nil {
+		return nil, fmt.Errorf("failed to parse baseline file %s: %w", path, err)
+	}
+	if f.Version > version {
+		return nil, fmt.Errorf("baseline file %s has version %d, but this ratchet only understands version %d", path, f.Version, version)
+	}
+	if f.Metrics == nil {
+		f.Metrics = make(map[string]float64)
+	}
+	return f, nil
+}
+
+// Save writes the baseline to path, with metrics in a stable order so that
+// changes are easy to review
+func (f *File) Save(path string) error {
+	f.Version = version
+	data, err := json.MarshalIndent(f, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode baseline: %w", err)
+	}
+	if err := os.WriteFile(path, append(data, '\n'), 0o644); err != nil {
+		return fmt.Errorf("failed to write baseline file %s: %w", path, err)
+	}
+	return nil
+}
+
+// Changes describes how local differs from trusted, one line per metric
+// added, removed or changed, in a stable order
+func Changes(trusted *File, local *File) []string {
+	names := make([]string, 0, len(trusted.Metrics))
+	for name := range trusted.Metrics {
+		names = append(names, name)
+	}
+	for name := range local.Metrics {
+		if _, ok := trusted.Metrics[name]; !ok {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+
+	var changes []string
+	for _, name := range names {
+		before, inTrusted := trusted.Metrics[name]
+		after, inLocal := local.Metrics[name]
+		switch {
+		case !inLocal:
+			changes = append(changes, fmt.Sprintf("metric '%s' removed", name))
+		case !inTrusted:
+			changes = append(changes, fmt.Sprintf("metric '%s' added", name))
+		case before != after:
+			changes = append(changes, fmt.Sprintf("metric '%s': %g -> %g", name, before, after))
+		}
+	}
+	return changes
+}
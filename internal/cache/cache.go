@@ -0,0 +1,105 @@
+package cache
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// Cache stores metric command output on disk, keyed by commit and commands
+type Cache struct {
+	dir string
+}
+
+// entry is the on-disk representation of a cached result
+type entry struct {
+	Commit   string    `json:"commit"`
+	Commands []string  `json:"commands"`
+	Output   string    `json:"output"`
+	Created  time.Time `json:"created"`
+}
+
+// DefaultDir returns the default cache location under the user cache directory
+func DefaultDir() (string, error) {
+	dir, err := os.UserCacheDir()
+	if err != nil {
+		return "", fmt.Errorf("failed to determine user cache directory: %w", err)
+	}
+	return filepath.Join(dir, "ratchet"), nil
+}
+
+// New returns a cache rooted at dir. The directory is created on first write.
+func New(dir string) *Cache {
+	return &Cache{dir: dir}
+}
+
+// Dir returns the directory the cache is stored in
+func (c *Cache) Dir() string {
+	return c.dir
+}
+
+// Key builds the cache key for a resolved commit SHA and the commands (pre,
+// metric and post) used to produce the output
+func Key(commit string, commands ...string) string {
+	hash := sha256.Sum256([]byte(strings.Join(commands, "\x00")))
+	return commit + "-" + hex.EncodeToString(hash[:])[:16]
+}
+
+// Get returns the cached output for key, if present
+func (c *Cache) Get(key string) (string, bool) {
+	data, err := os.ReadFile(c.path(key))
+	if err != nil {
+		return "", false
+	}
+
+	var e entry
+	if err := json.Unmarshal(data, &e); err != nil {
+		return "", false
+	}
+	return e.Output, true
+}
+
+// Put stores output under key
+func (c *Cache) Put(key string, commit string, commands []string, output string) error {
+	if err := os.MkdirAll(c.dir, 0o755); err != nil {
+		return fmt.Errorf("failed to create cache directory %s: %w", c.dir, err)
+	}
+
+	data, err := json.MarshalIndent(entry{
+		Commit:   commit,
+		Commands: commands,
+		Output:   output,
+		Created:  time.Now().UTC(),
+	}, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode cache entry: %w", err)
+	}
+
+	// Write to a temporary file and rename so concurrent runs never read a
+	// partial entry
+	tmpPath := fmt.Sprintf("%s.%d.tmp", c.path(key), os.Getpid())
+	if err := os.WriteFile(tmpPath, data, 0o644); err != nil {
+		return fmt.Errorf("failed to write cache entry: %w", err)
+	}
+	if err := os.Rename(tmpPath, c.path(key)); err != nil {
+		return fmt.Errorf("failed to write cache entry: %w", err)
+	}
+	return nil
+}
+
+// Clear removes every cached result
+func (c *Cache) Clear() error {
+	if err := os.RemoveAll(c.dir); err != nil {
+		return fmt.Errorf("failed to clear cache %s: %w", c.dir, err)
+	}
+	return nil
+}
+
+func (c *Cache) path(key string) string {
+	return filepath.Join(c.dir, key+".json")
+}
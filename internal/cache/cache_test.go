@@ -0,0 +1,60 @@
+package cache
+
+import (
+	"path/filepath"
+	"testing"
+)
+
+func TestKey(t *testing.T) {
+	commit := "0123456789abcdef0123456789abcdef01234567"
+	base := Key(commit, "", "grep -c TODO *", "")
+	if base != Key(commit, "", "grep -c TODO *", "") {
+		t.Error("the same commit and commands gave different keys")
+	}
+	for name, other := range map[string]string{
+		"commit":           Key("fedcba9876543210fedcba9876543210fedcba98", "", "grep -c TODO *", ""),
+		"metric command":   Key(commit, "", "grep -c FIXME *", ""),
+		"pre command":      Key(commit, "make", "grep -c TODO *", ""),
+		"post command":     Key(commit, "", "grep -c TODO *", "make clean"),
+		"command boundary": Key(commit, "", "grep -c TODO", " *"),
+	} {
+		if other == base {
+			t.Errorf("changing the %s kept the key %s", name, base)
+		}
+	}
+}
+
+func TestPutGetClear(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "cache")
+	c := New(dir)
+	key := Key("abc", "", "echo 1", "")
+
+	if _, ok := c.Get(key); ok {
+		t.Fatal("empty cache had an entry")
+	}
+	if err := c.Put(key, "abc", []string{"", "echo 1", ""}, "1\nmore output\n"); err != nil {
+		t.Fatal(err)
+	}
+	output, ok := c.Get(key)
+	if !ok || output != "1\nmore output\n" {
+		t.Fatalf("got %q, %v; want the stored output", output, ok)
+	}
+	if _, ok := c.Get(Key("abc", "", "echo 2", "")); ok {
+		t.Error("a different key hit the entry")
+	}
+
+	// A new cache on the same directory sees the entry
+	if _, ok := New(dir).Get(key); !ok {
+		t.Error("entry not read back from disk")
+	}
+
+	if err := c.Clear(); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := c.Get(key); ok {
+		t.Error("entry survived Clear")
+	}
+	if err := c.Clear(); err != nil {
+		t.Errorf("clearing an empty cache: %v", err)
+	}
+}
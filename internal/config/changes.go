@@ -0,0 +1,121 @@
+package config
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+)
+
+// Changes describes how local differs from trusted, one line per setting or
+// metric added, removed or changed, in a stable order. Metrics are matched by
+// name, so a renamed metric shows as one removed and one added.
+func Changes(trusted *Config, local *Config) []string {
+	var changes []string
+	changes = append(changes, diffSettings("setting ", settings(trusted), settings(local))...)
+
+	before := metricsByName(trusted.Metrics)
+	after := metricsByName(local.Metrics)
+	for _, name := range sortedKeys(before, after) {
+		b, inBefore := before[name]
+		a, inAfter := after[name]
+		switch {
+		case !inAfter:
+			changes = append(changes, fmt.Sprintf("metric '%s' removed", name))
+		case !inBefore:
+			changes = append(changes, fmt.Sprintf("metric '%s' added", name))
+		default:
+			changes = append(changes, diffSettings(fmt.Sprintf("metric '%s': ", name), b, a)...)
+		}
+	}
+	return changes
+}
+
+// diffSettings describes the settings that differ between two flattened
+// configs, each prefixed with what they belong to
+func diffSettings(prefix string, before map[string]string, after map[string]string) []string {
+	var changes []string
+	for _, key := range sortedKeys(before, after) {
+		if before[key] != after[key] {
+			changes = append(changes, fmt.Sprintf("%s%s %s -> %s", prefix, key, show(before[key]), show(after[key])))
+		}
+	}
+	return changes
+}
+
+// settings flattens everything in c apart from its list of metrics
+func settings(c *Config) map[string]string {
+	top := *c
+	top.Metrics = nil
+	flat := flatten(top)
+	delete(flat, "metrics")
+	return flat
+}
+
+// metricsByName flattens each metric, keyed by its name
+func metricsByName(metrics Metrics) map[string]map[string]string {
+	byName := make(map[string]map[string]string, len(metrics))
+	for _, m := range metrics {
+		flat := flatten(m)
+		delete(flat, "name")
+		byName[m.Name] = flat
+	}
+	return byName
+}
+
+// flatten returns the JSON form of v as dotted keys, such as "extract.regex",
+// mapped to JSON values, leaving out those that are null or empty lists
+func flatten(v interface{}) map[string]string {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return nil
+	}
+	var tree map[string]interface{}
+	if err := json.Unmarshal(data, &tree); err != nil {
+		return nil
+	}
+	flat := make(map[string]string)
+	var walk func(prefix string, node map[string]interface{})
+	walk = func(prefix string, node map[string]interface{}) {
+		for key, value := range node {
+			if child, ok := value.(map[string]interface{}); ok {
+				walk(prefix+key+".", child)
+				continue
+			}
+			// Zero values are kept, so that setting a threshold to 0 or
+			// clearing a command shows as a change
+			if value == nil {
+				continue
+			}
+			if list, ok := value.([]interface{}); ok && len(list) == 0 {
+				continue
+			}
+			encoded, _ := json.Marshal(value)
+			flat[prefix+key] = string(encoded)
+		}
+	}
+	walk("", tree)
+	return flat
+}
+
+// show returns a flattened value for display, or "(unset)"
+func show(value string) string {
+	if value == "" {
+		return "(unset)"
+	}
+	return value
+}
+
+// sortedKeys returns the keys of both maps, sorted and without repeats
+func sortedKeys[V any](a map[string]V, b map[string]V) []string {
+	var keys []string
+	for key := range a {
+		keys = append(keys, key)
+	}
+	for key := range b {
+		if _, ok := a[key]; !ok {
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -1,7 +1,9 @@
 package config
 
 import (
+	"bytes"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"os"
 	"path/filepath"
@@ -10,17 +12,235 @@ import (
 	"gopkg.in/yaml.v3"
 )
 
-// Config represents the configuration for ratchet
+// DefaultFile is the config file read from the current directory when no
+// other config is given
+const DefaultFile = ".ratchet"
+
+// ErrNoMetric is returned by Validate when there is nothing to measure
+var ErrNoMetric = errors.New("a metric command is required")
+
+// MetricConfig represents the configuration for a single metric
+type MetricConfig struct {
+	Name   string `yaml:"name" json:"name"`
+	Metric string `yaml:"metric" json:"metric"`
+	Pre    string `yaml:"pre" json:"pre"`
+	Post   string `yaml:"post" json:"post"`
+	LT     string `yaml:"lt" json:"lt"`
+	LE     string `yaml:"le" json:"le"`
+	EQ     string `yaml:"eq" json:"eq"`
+	GE     string `yaml:"ge" json:"ge"`
+	GT     string `yaml:"gt" json:"gt"`
+
+	// Tolerance is an absolute ("0.5") or relative ("1%") allowance
+	Tolerance string `yaml:"tolerance" json:"tolerance"`
+
+	// Timeouts are durations such as "30s" or "10m". Timeout applies to every
+	// step that does not set its own.
+	Timeout       string `yaml:"timeout" json:"timeout"`
+	PreTimeout    string `yaml:"pre_timeout" json:"pre_timeout"`
+	MetricTimeout string `yaml:"metric_timeout" json:"metric_timeout"`
+	PostTimeout   string `yaml:"post_timeout" json:"post_timeout"`
+
+	// Extract picks the value out of the metric output, which must otherwise
+	// be exactly one number
+	Extract ExtractConfig `yaml:"extract" json:"extract"`
+
+	// Items makes the output a list, one item per line, that ratchet counts;
+	// Normalize is "line-numbers" or a regular expression removed from each
+	// item before items are compared
+	Items     bool   `yaml:"items" json:"items"`
+	Normalize string `yaml:"normalize" json:"normalize"`
+
+	// Keys makes the output "key<TAB>number" lines, each compared on its own.
+	// NewKeys is "zero" (the default) or "allow"; DeletedKeys is "allow" (the
+	// default) or "fail".
+	Keys        bool   `yaml:"keys" json:"keys"`
+	NewKeys     string `yaml:"new_keys" json:"new_keys"`
+	DeletedKeys string `yaml:"deleted_keys" json:"deleted_keys"`
+
+	// SARIF is the SARIF log the metric command writes, or "-" for stdout;
+	// only results new in HEAD fail the comparison. SARIFOutput receives a
+	// copy of HEAD's log holding only those results.
+	SARIF       string `yaml:"sarif" json:"sarif"`
+	SARIFOutput string `yaml:"sarif_output" json:"sarif_output"`
+
+	// Coverage is the Go cover profile or LCOV trace the metric command
+	// writes, or "-" for stdout; ChangedLines measures HEAD on the lines
+	// changed since the base ref only
+	Coverage     string `yaml:"coverage" json:"coverage"`
+	ChangedLines bool   `yaml:"changed_lines" json:"changed_lines"`
+
+	// Threshold is compared with HEAD instead of the base's value
+	Threshold *float64 `yaml:"threshold" json:"threshold"`
+
+	// Count replaces the metric command with a built-in count of matching lines
+	Count *CountConfig `yaml:"count" json:"count"`
+
+	// BaseFailure is what happens when the metric cannot be measured on the
+	// base: "fail" (the default), "warn" to pass without comparing, or
+	// "default" to compare against BaseDefault. BaseHelpers are files copied
+	// from the working copy into the base worktree before it is measured.
+	BaseFailure string   `yaml:"base_failure" json:"base_failure"`
+	BaseDefault *float64 `yaml:"base_default" json:"base_default"`
+	BaseHelpers []string `yaml:"base_helpers" json:"base_helpers"`
+}
+
+// CountConfig describes a built-in count of the lines matching any of the
+// patterns, in the files git lists that pass the include and exclude globs
+type CountConfig struct {
+	Patterns []string `yaml:"patterns" json:"patterns"`
+	Include  []string `yaml:"include" json:"include"`
+	Exclude  []string `yaml:"exclude" json:"exclude"`
+}
+
+// ExtractConfig selects how the value is found in the metric output. At most
+// one mode may be set.
+type ExtractConfig struct {
+	Regex string `yaml:"regex" json:"regex"` // First capture group of a regular expression
+	JSON  string `yaml:"json" json:"json"`   // JSONPath-style selector, e.g. $.totals.percent
+	Last  bool   `yaml:"last" json:"last"`   // Last number on the last line
+	Key   string `yaml:"key" json:"key"`     // Value of a key in key=value lines
+}
+
+// Config represents the configuration for ratchet. The top-level metric fields
+// describe a single metric; when Metrics is set they act as defaults instead.
 type Config struct {
-	Metric  string `yaml:"metric" json:"metric"`
-	Pre     string `yaml:"pre" json:"pre"`
-	Post    string `yaml:"post" json:"post"`
-	LT      string `yaml:"lt" json:"lt"`
-	LE      string `yaml:"le" json:"le"`
-	EQ      string `yaml:"eq" json:"eq"`
-	GE      string `yaml:"ge" json:"ge"`
-	GT      string `yaml:"gt" json:"gt"`
-	Verbose bool   `yaml:"verbose" json:"verbose"`
+	MetricConfig  `yaml:",inline"`
+	Verbose       bool        `yaml:"verbose" json:"verbose"`
+	Parallel      bool        `yaml:"parallel" json:"parallel"`
+	MergeBase     bool        `yaml:"merge_base" json:"merge_base"`
+	BaselineFile  string      `yaml:"baseline_file" json:"baseline_file"`
+	Tighten       bool        `yaml:"tighten" json:"tighten"`
+	TightenCommit bool        `yaml:"tighten_commit" json:"tighten_commit"`
+	CacheDir      string      `yaml:"cache_dir" json:"cache_dir"`
+	NoCache       bool        `yaml:"no_cache" json:"no_cache"`
+	Notes         NotesConfig `yaml:"notes" json:"notes"`
+	Output        string      `yaml:"output" json:"output"`
+	OutputFile    string      `yaml:"output_file" json:"output_file"`
+	JUnit         string      `yaml:"junit" json:"junit"`
+	Metrics       Metrics     `yaml:"metrics" json:"metrics"`
+}
+
+// NotesConfig controls storing metric values in git notes
+type NotesConfig struct {
+	Read   bool   `yaml:"read" json:"read"`
+	Write  bool   `yaml:"write" json:"write"`
+	Remote string `yaml:"remote" json:"remote"`
+}
+
+// Flags holds the command-line values that can override the config
+type Flags struct {
+	Metric        string
+	Pre           string
+	Post          string
+	LT            string
+	LE            string
+	EQ            string
+	GE            string
+	GT            string
+	Verbose       bool
+	Parallel      bool
+	MergeBase     bool
+	BaselineFile  string
+	Tighten       bool
+	TightenCommit bool
+	CacheDir      string
+	NoCache       bool
+	NotesRead     bool
+	NotesWrite    bool
+	NotesRemote   string
+	Tolerance     string
+	Timeout       string
+	Items         bool
+	Normalize     string
+	Keys          bool
+	NewKeys       string
+	DeletedKeys   string
+	SARIF         string
+	SARIFOutput   string
+	Coverage      string
+	ChangedLines  bool
+	Threshold     *float64
+	Count         []string
+	Include       []string
+	Exclude       []string
+	BaseFailure   string
+	BaseDefault   *float64
+	BaseHelpers   []string
+	Output        string
+	OutputFile    string
+	JUnit         string
+}
+
+// Metrics is a list of named metrics. It may be written either as a list of
+// entries that each have a name, or as a map keyed by metric name.
+type Metrics []MetricConfig
+
+// UnmarshalYAML accepts either a list or a map of metrics
+func (m *Metrics) UnmarshalYAML(node *yaml.Node) error {
+	switch node.Kind {
+	case yaml.SequenceNode:
+		var list []MetricConfig
+		if err := node.Decode(&list); err != nil {
+			return err
+		}
+		*m = list
+	case yaml.MappingNode:
+		var list []MetricConfig
+		for i := 0; i+1 < len(node.Content); i += 2 {
+			var mc MetricConfig
+			if err := node.Content[i+1].Decode(&mc); err != nil {
+				return err
+			}
+			mc.Name = node.Content[i].Value
+			list = append(list, mc)
+		}
+		*m = list
+	default:
+		return fmt.Errorf("metrics must be a list or a map")
+	}
+	return nil
+}
+
+// UnmarshalJSON accepts either a list or an object of metrics, preserving the
+// order in which object keys appear
+func (m *Metrics) UnmarshalJSON(data []byte) error {
+	trimmed := bytes.TrimSpace(data)
+	if bytes.Equal(trimmed, []byte("null")) {
+		return nil
+	}
+	if len(trimmed) > 0 && trimmed[0] == '[' {
+		var list []MetricConfig
+		if err := json.Unmarshal(trimmed, &list); err != nil {
+			return err
+		}
+		*m = list
+		return nil
+	}
+
+	dec := json.NewDecoder(bytes.NewReader(trimmed))
+	if tok, err := dec.Token(); err != nil || tok != json.Delim('{') {
+		return fmt.Errorf("metrics must be a list or an object")
+	}
+	var list []MetricConfig
+	for dec.More() {
+		tok, err := dec.Token()
+		if err != nil {
+			return err
+		}
+		name, ok := tok.(string)
+		if !ok {
+			return fmt.Errorf("metrics must be a list or an object")
+		}
+		var mc MetricConfig
+		if err := dec.Decode(&mc); err != nil {
+			return err
+		}
+		mc.Name = name
+		list = append(list, mc)
+	}
+	*m = list
+	return nil
 }
 
 // LoadFromFile loads configuration from a YAML or JSON file
@@ -29,10 +249,16 @@ func LoadFromFile(path string) (*Config, error) {
 	if err != nil {
 		return nil, fmt.Errorf("failed to read config file %s", path)
 	}
+	return LoadFromData(path, data)
+}
 
+// LoadFromData loads configuration read from path, which may name a file in
+// a commit rather than on disk, choosing YAML or JSON by its extension
+func LoadFromData(path string, data []byte) (*Config, error) {
 	// Determine format based on file extension
 	ext := strings.ToLower(filepath.Ext(path))
 	var cfg *Config
+	var err error
 
 	switch ext {
 	case ".json":
@@ -101,110 +327,587 @@ func LoadFromConfigString(configStr string) (*Config, error) {
 
 // Validate ensures the configuration is valid
 func (c *Config) Validate() error {
-	if c.Metric == "" {
-		return fmt.Errorf("a metric command is required")
+	if c.MergeBase && c.BaselineFile != "" {
+		return fmt.Errorf("merge_base and baseline_file cannot both be specified")
+	}
+	if (c.Tighten || c.TightenCommit) && c.BaselineFile == "" && !c.hasThreshold() {
+		return fmt.Errorf("tighten requires baseline_file or a threshold")
+	}
+	for _, m := range c.ResolveMetrics() {
+		if c.BaselineFile != "" && (m.ChangedLines || m.Threshold != nil) {
+			return fmt.Errorf("changed_lines and threshold cannot be used with baseline_file")
+		}
+		if c.BaselineFile != "" && (m.BaseFailure != "" || m.BaseDefault != nil || len(m.BaseHelpers) > 0) {
+			return fmt.Errorf("base_failure, base_default and base_helpers cannot be used with baseline_file")
+		}
+		if err := m.validateBase(); err != nil {
+			if m.Name != "" {
+				return fmt.Errorf("metric '%s': %w", m.Name, err)
+			}
+			return err
+		}
+	}
+
+	if len(c.Metrics) == 0 {
+		if c.Metric == "" && c.Count == nil {
+			return ErrNoMetric
+		}
+		if c.comparisonCount() > 1 {
+			return fmt.Errorf("only one comparison operator can be specified")
+		}
+		if c.Extract.modeCount() > 1 {
+			return fmt.Errorf("only one extract mode can be specified")
+		}
+		if err := c.MetricConfig.validateOutput(); err != nil {
+			return err
+		}
+		return nil
+	}
+
+	if c.Metric != "" {
+		return fmt.Errorf("metric and metrics cannot both be specified")
+	}
+	if c.Count != nil {
+		return fmt.Errorf("count and metrics cannot both be specified")
+	}
+	if c.comparisonCount() > 1 {
+		return fmt.Errorf("only one comparison operator can be specified")
+	}
+
+	seen := make(map[string]bool)
+	sarifOutputs := make(map[string]bool)
+	// Entries are checked with the top-level settings they inherit
+	for i, m := range c.ResolveMetrics() {
+		if m.Name == "" {
+			return fmt.Errorf("metric %d requires a name", i+1)
+		}
+		if seen[m.Name] {
+			return fmt.Errorf("metric name '%s' is used more than once", m.Name)
+		}
+		seen[m.Name] = true
+		if m.Metric == "" && m.Count == nil {
+			return fmt.Errorf("metric '%s' requires a metric command or count", m.Name)
+		}
+		if m.comparisonCount() > 1 {
+			return fmt.Errorf("metric '%s' specifies more than one comparison operator", m.Name)
+		}
+		if m.Extract.modeCount() > 1 {
+			return fmt.Errorf("metric '%s' specifies more than one extract mode", m.Name)
+		}
+		if err := m.validateOutput(); err != nil {
+			return fmt.Errorf("metric '%s': %w", m.Name, err)
+		}
+		if m.SARIFOutput != "" {
+			if sarifOutputs[m.SARIFOutput] {
+				return fmt.Errorf("sarif_output '%s' is used by more than one metric", m.SARIFOutput)
+			}
+			sarifOutputs[m.SARIFOutput] = true
+		}
+	}
+
+	return nil
+}
+
+// hasThreshold reports whether any metric is compared with a fixed threshold
+func (c *Config) hasThreshold() bool {
+	for _, m := range c.ResolveMetrics() {
+		if m.Threshold != nil {
+			return true
+		}
+	}
+	return false
+}
+
+// validateBase checks the settings for when the base cannot be measured
+func (m *MetricConfig) validateBase() error {
+	switch m.BaseFailure {
+	case "", "fail", "warn":
+		if m.BaseDefault != nil {
+			return fmt.Errorf("base_default requires base_failure: default")
+		}
+	case "default":
+		if m.BaseDefault == nil {
+			return fmt.Errorf("base_failure default requires base_default")
+		}
+	default:
+		return fmt.Errorf("invalid base_failure '%s': expected fail, warn or default", m.BaseFailure)
+	}
+	for _, path := range m.BaseHelpers {
+		clean := filepath.ToSlash(filepath.Clean(path))
+		if filepath.IsAbs(path) || clean == ".." || strings.HasPrefix(clean, "../") {
+			return fmt.Errorf("base helper '%s' must be a path inside the repository", path)
+		}
+	}
+	return nil
+}
+
+// outputModeCount returns how many ways of reading the metric output are set
+func (m *MetricConfig) outputModeCount() int {
+	modes := 0
+	if m.Extract.modeCount() > 0 {
+		modes++
+	}
+	if m.Items {
+		modes++
+	}
+	if m.Keys {
+		modes++
 	}
+	if m.SARIF != "" {
+		modes++
+	}
+	if m.Coverage != "" {
+		modes++
+	}
+	return modes
+}
+
+// validateOutput checks that at most one way of reading the metric output is
+// chosen, and that the settings for it are consistent
+func (m *MetricConfig) validateOutput() error {
+	if m.Count != nil {
+		if m.Metric != "" {
+			return fmt.Errorf("metric and count cannot both be specified")
+		}
+		if len(m.Count.Patterns) == 0 {
+			return fmt.Errorf("count requires at least one pattern")
+		}
+		if m.Extract.modeCount() > 0 || m.SARIF != "" || m.Coverage != "" {
+			return fmt.Errorf("count cannot be combined with extract, sarif or coverage")
+		}
+	}
+	if m.outputModeCount() > 1 {
+		return fmt.Errorf("only one of extract, items, keys, sarif and coverage can be specified")
+	}
+	if m.ChangedLines && m.Coverage == "" {
+		return fmt.Errorf("changed_lines requires coverage")
+	}
+	if m.SARIFOutput != "" && m.SARIF == "" {
+		return fmt.Errorf("sarif_output requires sarif")
+	}
+	if m.Normalize != "" && !m.Items {
+		return fmt.Errorf("normalize requires items")
+	}
+	if (m.NewKeys != "" || m.DeletedKeys != "") && !m.Keys {
+		return fmt.Errorf("new_keys and deleted_keys require keys")
+	}
+	if m.NewKeys != "" && m.NewKeys != "zero" && m.NewKeys != "allow" {
+		return fmt.Errorf("invalid new_keys '%s': expected zero or allow", m.NewKeys)
+	}
+	if m.DeletedKeys != "" && m.DeletedKeys != "allow" && m.DeletedKeys != "fail" {
+		return fmt.Errorf("invalid deleted_keys '%s': expected allow or fail", m.DeletedKeys)
+	}
+	return nil
+}
 
-	// Count comparison operators
+// comparisonCount returns the number of comparison operators that are set
+func (m *MetricConfig) comparisonCount() int {
 	count := 0
-	if c.LT != "" {
+	if m.LT != "" {
 		count++
 	}
-	if c.LE != "" {
+	if m.LE != "" {
 		count++
 	}
-	if c.EQ != "" {
+	if m.EQ != "" {
 		count++
 	}
-	if c.GE != "" {
+	if m.GE != "" {
 		count++
 	}
-	if c.GT != "" {
+	if m.GT != "" {
 		count++
 	}
+	return count
+}
 
-	if count > 1 {
-		return fmt.Errorf("only one comparison operator can be specified")
+// modeCount returns the number of extract modes that are set
+func (e *ExtractConfig) modeCount() int {
+	count := 0
+	if e.Regex != "" {
+		count++
+	}
+	if e.JSON != "" {
+		count++
 	}
+	if e.Last {
+		count++
+	}
+	if e.Key != "" {
+		count++
+	}
+	return count
+}
 
-	return nil
+// GetExtractInfo returns the extract mode and its expression, or empty
+// strings if the output is used whole
+func (e *ExtractConfig) GetExtractInfo() (mode string, expr string) {
+	if e.Regex != "" {
+		return "regex", e.Regex
+	}
+	if e.JSON != "" {
+		return "json", e.JSON
+	}
+	if e.Last {
+		return "last", ""
+	}
+	if e.Key != "" {
+		return "key", e.Key
+	}
+	return "", ""
+}
+
+// clearComparison removes any comparison operator
+func (m *MetricConfig) clearComparison() {
+	m.LT = ""
+	m.LE = ""
+	m.EQ = ""
+	m.GE = ""
+	m.GT = ""
+}
+
+// Overlay applies the settings given in other over c. As with flags, a
+// metric, count or list of metrics in other replaces those in c. Empty
+// strings and false leave c's settings alone, as an unset action input
+// arrives as "", but numbers such as a zero threshold are applied.
+func (c *Config) Overlay(other *Config) {
+	if other.Metric != "" || other.Count != nil || len(other.Metrics) > 0 {
+		c.Metric = ""
+		c.Count = nil
+		c.Metrics = nil
+	}
+
+	data, err := json.Marshal(other)
+	if err != nil {
+		return
+	}
+	var tree map[string]interface{}
+	if err := json.Unmarshal(data, &tree); err != nil {
+		return
+	}
+	prune(tree)
+	if data, err = json.Marshal(tree); err != nil {
+		return
+	}
+	// Unmarshalling over c only replaces the fields present in the data
+	_ = json.Unmarshal(data, c)
+}
+
+// prune removes unset values from a JSON object, and objects left empty
+func prune(tree map[string]interface{}) {
+	for key, value := range tree {
+		if child, ok := value.(map[string]interface{}); ok {
+			prune(child)
+			if len(child) == 0 {
+				delete(tree, key)
+			}
+			continue
+		}
+		if list, ok := value.([]interface{}); ok && len(list) == 0 {
+			delete(tree, key)
+			continue
+		}
+		switch value {
+		case nil, "", false:
+			delete(tree, key)
+		}
+	}
 }
 
 // MergeWithFlags merges config with command-line flags, with flags taking precedence
-func (c *Config) MergeWithFlags(metric string, pre string, post string, lt string, le string, equalTo string, ge string, gt string, verbose bool) {
-	// Metric from args takes precedence
-	if metric != "" {
-		c.Metric = metric
+func (c *Config) MergeWithFlags(f Flags) {
+	// Metric from args takes precedence, replacing any configured metrics
+	if f.Metric != "" {
+		c.Metric = f.Metric
+		c.Count = nil
+		c.Metrics = nil
+	}
+	// A count from flags likewise replaces a configured metric, unless one
+	// was also given as an argument, which Validate then rejects
+	if len(f.Count) > 0 {
+		c.Count = &CountConfig{Patterns: f.Count, Include: f.Include, Exclude: f.Exclude}
+		if f.Metric == "" {
+			c.Metric = ""
+		}
+		c.Metrics = nil
 	}
 
 	// Flags take precedence over config file
-	if pre != "" {
-		c.Pre = pre
+	if f.Pre != "" {
+		c.Pre = f.Pre
+		for i := range c.Metrics {
+			c.Metrics[i].Pre = f.Pre
+		}
 	}
-	if post != "" {
-		c.Post = post
+	if f.Post != "" {
+		c.Post = f.Post
+		for i := range c.Metrics {
+			c.Metrics[i].Post = f.Post
+		}
 	}
 
 	// Check if any CLI comparison operator is provided
-	cliHasComparison := lt != "" || le != "" || equalTo != "" || ge != "" || gt != ""
+	cliHasComparison := f.LT != "" || f.LE != "" || f.EQ != "" || f.GE != "" || f.GT != ""
 
 	// If CLI has a comparison operator, clear all config comparison operators first,
 	// then set the CLI one. This allows CLI to override config even with different operators.
+	// Named metrics inherit the CLI operator through the top-level defaults.
 	if cliHasComparison {
-		c.LT = ""
-		c.LE = ""
-		c.EQ = ""
-		c.GE = ""
-		c.GT = ""
+		c.clearComparison()
+		for i := range c.Metrics {
+			c.Metrics[i].clearComparison()
+		}
 
 		// Now set the CLI comparison operator
-		if lt != "" {
-			c.LT = lt
+		if f.LT != "" {
+			c.LT = f.LT
+		}
+		if f.LE != "" {
+			c.LE = f.LE
+		}
+		if f.EQ != "" {
+			c.EQ = f.EQ
+		}
+		if f.GE != "" {
+			c.GE = f.GE
+		}
+		if f.GT != "" {
+			c.GT = f.GT
+		}
+	}
+
+	if f.Tolerance != "" {
+		c.Tolerance = f.Tolerance
+		for i := range c.Metrics {
+			c.Metrics[i].Tolerance = f.Tolerance
+		}
+	}
+
+	if f.Timeout != "" {
+		c.Timeout = f.Timeout
+		for i := range c.Metrics {
+			c.Metrics[i].Timeout = f.Timeout
+		}
+	}
+
+	if f.Items {
+		c.Items = true
+		for i := range c.Metrics {
+			c.Metrics[i].Items = true
+		}
+	}
+	if f.Normalize != "" {
+		c.Normalize = f.Normalize
+		for i := range c.Metrics {
+			c.Metrics[i].Normalize = f.Normalize
+		}
+	}
+	if f.Keys {
+		c.Keys = true
+		for i := range c.Metrics {
+			c.Metrics[i].Keys = true
+		}
+	}
+	if f.NewKeys != "" {
+		c.NewKeys = f.NewKeys
+		for i := range c.Metrics {
+			c.Metrics[i].NewKeys = f.NewKeys
+		}
+	}
+	if f.DeletedKeys != "" {
+		c.DeletedKeys = f.DeletedKeys
+		for i := range c.Metrics {
+			c.Metrics[i].DeletedKeys = f.DeletedKeys
 		}
-		if le != "" {
-			c.LE = le
+	}
+	if f.SARIF != "" {
+		c.SARIF = f.SARIF
+		for i := range c.Metrics {
+			c.Metrics[i].SARIF = f.SARIF
 		}
-		if equalTo != "" {
-			c.EQ = equalTo
+	}
+	if f.SARIFOutput != "" {
+		c.SARIFOutput = f.SARIFOutput
+		for i := range c.Metrics {
+			c.Metrics[i].SARIFOutput = f.SARIFOutput
 		}
-		if ge != "" {
-			c.GE = ge
+	}
+	if f.Coverage != "" {
+		c.Coverage = f.Coverage
+		for i := range c.Metrics {
+			c.Metrics[i].Coverage = f.Coverage
 		}
-		if gt != "" {
-			c.GT = gt
+	}
+	if f.ChangedLines {
+		c.ChangedLines = true
+		for i := range c.Metrics {
+			c.Metrics[i].ChangedLines = true
+		}
+	}
+	if f.Threshold != nil {
+		c.Threshold = f.Threshold
+		for i := range c.Metrics {
+			c.Metrics[i].Threshold = f.Threshold
+		}
+	}
+	if f.BaseFailure != "" {
+		c.BaseFailure = f.BaseFailure
+		for i := range c.Metrics {
+			c.Metrics[i].BaseFailure = f.BaseFailure
+		}
+	}
+	if f.BaseDefault != nil {
+		c.BaseDefault = f.BaseDefault
+		for i := range c.Metrics {
+			c.Metrics[i].BaseDefault = f.BaseDefault
+		}
+	}
+	if len(f.BaseHelpers) > 0 {
+		c.BaseHelpers = f.BaseHelpers
+		for i := range c.Metrics {
+			c.Metrics[i].BaseHelpers = f.BaseHelpers
 		}
 	}
 
-	if verbose {
+	if f.Verbose {
 		c.Verbose = true
 	}
+	if f.Parallel {
+		c.Parallel = true
+	}
+	if f.MergeBase {
+		c.MergeBase = true
+	}
+	if f.BaselineFile != "" {
+		c.BaselineFile = f.BaselineFile
+	}
+	if f.Tighten {
+		c.Tighten = true
+	}
+	if f.TightenCommit {
+		c.TightenCommit = true
+	}
+	if f.CacheDir != "" {
+		c.CacheDir = f.CacheDir
+	}
+	if f.NoCache {
+		c.NoCache = true
+	}
+	if f.NotesRead {
+		c.Notes.Read = true
+	}
+	if f.NotesWrite {
+		c.Notes.Write = true
+	}
+	if f.NotesRemote != "" {
+		c.Notes.Remote = f.NotesRemote
+	}
+	if f.Output != "" {
+		c.Output = f.Output
+	}
+	if f.OutputFile != "" {
+		c.OutputFile = f.OutputFile
+	}
+	if f.JUnit != "" {
+		c.JUnit = f.JUnit
+	}
 }
 
 // GetComparisonInfo returns the comparison type and base reference
-func (c *Config) GetComparisonInfo() (compType string, baseRef string) {
-	if c.LT != "" {
-		return "lt", c.LT
+func (m *MetricConfig) GetComparisonInfo() (compType string, baseRef string) {
+	if m.LT != "" {
+		return "lt", m.LT
 	}
-	if c.LE != "" {
-		return "le", c.LE
+	if m.LE != "" {
+		return "le", m.LE
 	}
-	if c.EQ != "" {
-		return "eq", c.EQ
+	if m.EQ != "" {
+		return "eq", m.EQ
 	}
-	if c.GE != "" {
-		return "ge", c.GE
+	if m.GE != "" {
+		return "ge", m.GE
 	}
-	if c.GT != "" {
-		return "gt", c.GT
+	if m.GT != "" {
+		return "gt", m.GT
 	}
 	return "", ""
 }
 
+// ResolveMetrics returns the metrics to evaluate. Without a metrics section the
+// top-level fields describe a single metric; otherwise the top-level pre, post,
+// comparison, tolerance, timeout, threshold, output and base failure settings
+// are defaults for entries that do not set their own. The way of reading the
+// output (extract, items, keys, sarif or coverage) is inherited as a whole by
+// entries that choose none, and its options by entries reading it that way.
+func (c *Config) ResolveMetrics() []MetricConfig {
+	if len(c.Metrics) == 0 {
+		return []MetricConfig{c.MetricConfig}
+	}
+
+	resolved := make([]MetricConfig, len(c.Metrics))
+	for i, m := range c.Metrics {
+		if m.Pre == "" {
+			m.Pre = c.Pre
+		}
+		if m.Post == "" {
+			m.Post = c.Post
+		}
+		if m.comparisonCount() == 0 {
+			m.LT, m.LE, m.EQ, m.GE, m.GT = c.LT, c.LE, c.EQ, c.GE, c.GT
+		}
+		if m.Tolerance == "" {
+			m.Tolerance = c.Tolerance
+		}
+		if m.Timeout == "" {
+			m.Timeout = c.Timeout
+		}
+		if m.PreTimeout == "" {
+			m.PreTimeout = c.PreTimeout
+		}
+		if m.MetricTimeout == "" {
+			m.MetricTimeout = c.MetricTimeout
+		}
+		if m.PostTimeout == "" {
+			m.PostTimeout = c.PostTimeout
+		}
+		if m.Threshold == nil {
+			m.Threshold = c.Threshold
+		}
+		if m.outputModeCount() == 0 {
+			m.Extract, m.Items, m.Keys = c.Extract, c.Items, c.Keys
+			m.SARIF, m.SARIFOutput, m.Coverage = c.SARIF, c.SARIFOutput, c.Coverage
+		}
+		if m.Items && m.Normalize == "" {
+			m.Normalize = c.Normalize
+		}
+		if m.Keys && m.NewKeys == "" {
+			m.NewKeys = c.NewKeys
+		}
+		if m.Keys && m.DeletedKeys == "" {
+			m.DeletedKeys = c.DeletedKeys
+		}
+		if m.Coverage != "" && !m.ChangedLines {
+			m.ChangedLines = c.ChangedLines
+		}
+		if m.BaseFailure == "" {
+			m.BaseFailure = c.BaseFailure
+		}
+		if m.BaseDefault == nil {
+			m.BaseDefault = c.BaseDefault
+		}
+		if len(m.BaseHelpers) == 0 {
+			m.BaseHelpers = c.BaseHelpers
+		}
+		resolved[i] = m
+	}
+	return resolved
+}
+
 // LoadDefault attempts to load config from default locations
 func LoadDefault() (*Config, error) {
 	// Look for .ratchet in current directory
-	if _, err := os.Stat(".ratchet"); err == nil {
-		return LoadFromFile(".ratchet")
+	if _, err := os.Stat(DefaultFile); err == nil {
+		return LoadFromFile(DefaultFile)
 	}
 
 	// No default config found
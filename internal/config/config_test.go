@@ -0,0 +1,147 @@
+package config
+
+import "testing"
+
+func TestMergeWithFlagsCountReplacesConfiguredMetric(t *testing.T) {
+	c, err := LoadFromString("metric: grep -r TODO . | wc -l\nle: main\n")
+	if err != nil {
+		t.Fatal(err)
+	}
+	c.MergeWithFlags(Flags{Count: []string{"TODO"}})
+	if err := c.Validate(); err != nil {
+		t.Fatalf("Validate: %v", err)
+	}
+	if c.Metric != "" || c.Count == nil {
+		t.Errorf("got metric %q, count %v; want the count only", c.Metric, c.Count)
+	}
+}
+
+func TestMergeWithFlagsCountAndMetricArgument(t *testing.T) {
+	c := &Config{}
+	c.MergeWithFlags(Flags{Metric: "echo 1", Count: []string{"TODO"}})
+	if err := c.Validate(); err == nil {
+		t.Error("expected a metric argument and --count together to be rejected")
+	}
+}
+
+func TestOverlay(t *testing.T) {
+	trusted, err := LoadFromString("le: main\nthreshold: 5\nverbose: true\nmetrics:\n  a:\n    metric: echo 1\n")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Settings left as "" or false, as unset action inputs are, change nothing
+	inline, err := LoadFromJSONString(`{"metric": "", "pre": "", "lt": "", "verbose": false, "threshold": 0, "version": "latest"}`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	c := *trusted
+	c.Overlay(inline)
+	if c.LE != "main" || !c.Verbose || len(c.Metrics) != 1 || c.Metrics[0].Name != "a" {
+		t.Errorf("empty settings changed the trusted config: %+v", c)
+	}
+	if c.Threshold == nil || *c.Threshold != 0 {
+		t.Errorf("threshold %v, want the explicit 0", c.Threshold)
+	}
+
+	// A metric replaces the trusted metrics
+	inline, err = LoadFromJSONString(`{"metric": "echo 2", "pre": "make"}`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	c = *trusted
+	c.Overlay(inline)
+	if c.Metric != "echo 2" || c.Pre != "make" || len(c.Metrics) != 0 || c.LE != "main" {
+		t.Errorf("got metric %q, pre %q, %d metrics, le %q; want echo 2 with make, no metrics and le main", c.Metric, c.Pre, len(c.Metrics), c.LE)
+	}
+}
+
+func TestChangesKeepsZeroValues(t *testing.T) {
+	trusted, err := LoadFromString("metric: echo 1\nthreshold: 5\npre: make\n")
+	if err != nil {
+		t.Fatal(err)
+	}
+	local, err := LoadFromString("metric: echo 1\nthreshold: 0\npre: \"\"\nbase_default: 0\n")
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := Changes(trusted, local)
+	want := []string{
+		"setting base_default (unset) -> 0",
+		`setting pre "make" -> ""`,
+		"setting threshold 5 -> 0",
+	}
+	if len(got) != len(want) {
+		t.Fatalf("got %q, want %q", got, want)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("change %d: got %q, want %q", i, got[i], want[i])
+		}
+	}
+}
+
+func TestResolveMetricsInheritsOutputSettings(t *testing.T) {
+	c, err := LoadFromString(`le: main
+threshold: 10
+keys: true
+new_keys: allow
+metrics:
+  per-file:
+    metric: ./per-file.sh
+  strict:
+    metric: ./strict.sh
+    threshold: 3
+    deleted_keys: fail
+  listed:
+    metric: ./list.sh
+    items: true
+  extracted:
+    metric: ./report.sh
+    extract:
+      last: true
+`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := c.Validate(); err != nil {
+		t.Fatalf("Validate: %v", err)
+	}
+
+	got := make(map[string]MetricConfig)
+	for _, m := range c.ResolveMetrics() {
+		got[m.Name] = m
+	}
+	if m := got["per-file"]; !m.Keys || m.NewKeys != "allow" || m.Threshold == nil || *m.Threshold != 10 {
+		t.Errorf("per-file: keys %v, new_keys %q, threshold %v; want the top-level keys settings and threshold 10", m.Keys, m.NewKeys, m.Threshold)
+	}
+	if m := got["strict"]; !m.Keys || m.NewKeys != "allow" || m.DeletedKeys != "fail" || *m.Threshold != 3 {
+		t.Errorf("strict: keys %v, new_keys %q, deleted_keys %q, threshold %v; want its own settings over the top-level ones", m.Keys, m.NewKeys, m.DeletedKeys, *m.Threshold)
+	}
+	// An entry choosing its own way of reading the output takes none of the
+	// top-level one
+	if m := got["listed"]; !m.Items || m.Keys || m.NewKeys != "" {
+		t.Errorf("listed: items %v, keys %v, new_keys %q; want items alone", m.Items, m.Keys, m.NewKeys)
+	}
+	if m := got["extracted"]; !m.Extract.Last || m.Keys {
+		t.Errorf("extracted: extract %+v, keys %v; want extract alone", m.Extract, m.Keys)
+	}
+}
+
+func TestValidateChecksInheritedSettings(t *testing.T) {
+	for _, yaml := range []string{
+		// Each entry would write the same file
+		"sarif: \"-\"\nsarif_output: new.sarif\nmetrics:\n  a:\n    metric: echo a\n  b:\n    metric: echo b\n",
+		// The threshold each entry inherits cannot be combined with a baseline
+		"baseline_file: .ratchet-baseline.json\nthreshold: 5\nmetrics:\n  a:\n    metric: echo a\n",
+		"count:\n  patterns: [TODO]\nmetrics:\n  a:\n    metric: echo a\n",
+	} {
+		c, err := LoadFromString(yaml)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := c.Validate(); err == nil {
+			t.Errorf("expected config to be rejected:\n%s", yaml)
+		}
+	}
+}
@@ -0,0 +1,163 @@
+package config
+
+import (
+	"fmt"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Thresholds returns the thresholds set in the config file at path: by name
+// for each entry under metrics that sets one, or under "" for the single
+// metric described by the top-level keys
+func Thresholds(path string) (map[string]float64, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read config file %s", path)
+	}
+	nodes, err := thresholdNodes(path, data)
+	if err != nil {
+		return nil, err
+	}
+	values := make(map[string]float64, len(nodes))
+	for name, node := range nodes {
+		v, err := strconv.ParseFloat(node.Value, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid threshold '%s' in %s", node.Value, path)
+		}
+		values[name] = v
+	}
+	return values, nil
+}
+
+// SetThresholds rewrites thresholds in the config file at path, keyed as
+// Thresholds returns them. Only the numbers are replaced, so the comments and
+// layout of the file are kept, in YAML and JSON alike.
+func SetThresholds(path string, values map[string]float64) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("failed to read config file %s", path)
+	}
+	nodes, err := thresholdNodes(path, data)
+	if err != nil {
+		return err
+	}
+
+	type edit struct {
+		offset int
+		length int
+		value  string
+	}
+	var edits []edit
+	for name, v := range values {
+		node, ok := nodes[name]
+		if !ok {
+			return fmt.Errorf("no threshold for metric '%s' in %s", name, path)
+		}
+		offset, ok := nodeOffset(data, node)
+		if !ok || !strings.HasPrefix(string(data[offset:]), node.Value) {
+			return fmt.Errorf("cannot rewrite threshold '%s' in %s", node.Value, path)
+		}
+		edits = append(edits, edit{offset, len(node.Value), strconv.FormatFloat(v, 'g', -1, 64)})
+	}
+
+	// Apply from the end of the file, so earlier offsets stay valid
+	sort.Slice(edits, func(i, j int) bool { return edits[i].offset > edits[j].offset })
+	for _, e := range edits {
+		data = append(data[:e.offset:e.offset], append([]byte(e.value), data[e.offset+e.length:]...)...)
+	}
+
+	info, err := os.Stat(path)
+	if err != nil {
+		return fmt.Errorf("failed to write config file %s: %w", path, err)
+	}
+	if err := os.WriteFile(path, data, info.Mode().Perm()); err != nil {
+		return fmt.Errorf("failed to write config file %s: %w", path, err)
+	}
+	return nil
+}
+
+// thresholdNodes finds the plain number given as each threshold in a config
+// file, keyed as Thresholds returns them
+func thresholdNodes(path string, data []byte) (map[string]*yaml.Node, error) {
+	var doc yaml.Node
+	if err := yaml.Unmarshal(data, &doc); err != nil {
+		return nil, fmt.Errorf("failed to parse config file %s", path)
+	}
+	nodes := make(map[string]*yaml.Node)
+	if len(doc.Content) == 0 || doc.Content[0].Kind != yaml.MappingNode {
+		return nodes, nil
+	}
+	root := doc.Content[0]
+
+	add := func(name string, entry *yaml.Node) error {
+		node := mappingValue(entry, "threshold")
+		if node == nil || node.Kind != yaml.ScalarNode || node.Value == "null" {
+			return nil
+		}
+		if node.Style != 0 || (node.Tag != "!!int" && node.Tag != "!!float") {
+			return fmt.Errorf("threshold '%s' in %s must be written as a plain number to be tightened", node.Value, path)
+		}
+		nodes[name] = node
+		return nil
+	}
+
+	metrics := mappingValue(root, "metrics")
+	switch {
+	case metrics == nil:
+		return nodes, add("", root)
+	case metrics.Kind == yaml.MappingNode:
+		for i := 0; i+1 < len(metrics.Content); i += 2 {
+			if err := add(metrics.Content[i].Value, metrics.Content[i+1]); err != nil {
+				return nil, err
+			}
+		}
+	case metrics.Kind == yaml.SequenceNode:
+		for _, entry := range metrics.Content {
+			if name := mappingValue(entry, "name"); name != nil {
+				if err := add(name.Value, entry); err != nil {
+					return nil, err
+				}
+			}
+		}
+	}
+	return nodes, nil
+}
+
+// mappingValue returns the value of key in a mapping node, or nil
+func mappingValue(node *yaml.Node, key string) *yaml.Node {
+	if node.Kind != yaml.MappingNode {
+		return nil
+	}
+	for i := 0; i+1 < len(node.Content); i += 2 {
+		if node.Content[i].Value == key {
+			return node.Content[i+1]
+		}
+	}
+	return nil
+}
+
+// nodeOffset converts the line and column of a node, counted in characters
+// from 1, to a byte offset in data
+func nodeOffset(data []byte, node *yaml.Node) (int, bool) {
+	offset := 0
+	for line := 1; line < node.Line; line++ {
+		i := strings.IndexByte(string(data[offset:]), '\n')
+		if i < 0 {
+			return 0, false
+		}
+		offset += i + 1
+	}
+	for col := 1; col < node.Column; col++ {
+		if offset >= len(data) || data[offset] == '\n' {
+			return 0, false
+		}
+		_, size := utf8.DecodeRune(data[offset:])
+		offset += size
+	}
+	return offset, true
+}
@@ -0,0 +1,101 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestSetThresholds(t *testing.T) {
+	tests := []struct {
+		name   string
+		file   string
+		values map[string]float64
+		want   string
+	}{
+		{
+			name:   "top-level metric",
+			file:   "metric: echo 3\nthreshold: 5 # keep\nle: main\n",
+			values: map[string]float64{"": 3},
+			want:   "metric: echo 3\nthreshold: 3 # keep\nle: main\n",
+		},
+		{
+			name:   "map of metrics",
+			file:   "# Lower is better\nmetrics:\n  todos:\n    metric: echo 3\n    threshold: 12.5\n  other:\n    metric: echo 1\n    threshold: 7\n",
+			values: map[string]float64{"todos": 3, "other": 1},
+			want:   "# Lower is better\nmetrics:\n  todos:\n    metric: echo 3\n    threshold: 3\n  other:\n    metric: echo 1\n    threshold: 1\n",
+		},
+		{
+			name:   "list of metrics",
+			file:   "metrics:\n  - name: a\n    metric: echo 3\n    threshold: 10\n",
+			values: map[string]float64{"a": 80.25},
+			want:   "metrics:\n  - name: a\n    metric: echo 3\n    threshold: 80.25\n",
+		},
+		{
+			name:   "JSON",
+			file:   "{\n  \"metrics\": {\"é\": {\"metric\": \"echo 2\", \"threshold\": 4.5}}\n}\n",
+			values: map[string]float64{"é": 2},
+			want:   "{\n  \"metrics\": {\"é\": {\"metric\": \"echo 2\", \"threshold\": 2}}\n}\n",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), ".ratchet")
+			if err := os.WriteFile(path, []byte(tt.file), 0o644); err != nil {
+				t.Fatal(err)
+			}
+			if err := SetThresholds(path, tt.values); err != nil {
+				t.Fatal(err)
+			}
+			data, err := os.ReadFile(path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if string(data) != tt.want {
+				t.Errorf("got\n%s\nwant\n%s", data, tt.want)
+			}
+
+			// The rewritten file reads back with the new values
+			got, err := Thresholds(path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			for name, v := range tt.values {
+				if got[name] != v {
+					t.Errorf("threshold %q reads back as %g, want %g", name, got[name], v)
+				}
+			}
+		})
+	}
+}
+
+func TestThresholds(t *testing.T) {
+	path := filepath.Join(t.TempDir(), ".ratchet")
+	file := "threshold: 9\nmetrics:\n  a:\n    metric: echo 1\n    threshold: 2\n  b:\n    metric: echo 1\n"
+	if err := os.WriteFile(path, []byte(file), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	got, err := Thresholds(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// The top-level threshold is not a default for named metrics
+	if len(got) != 1 || got["a"] != 2 {
+		t.Errorf("got %v, want only a=2", got)
+	}
+
+	if err := SetThresholds(path, map[string]float64{"b": 1}); err == nil || !strings.Contains(err.Error(), "no threshold for metric 'b'") {
+		t.Errorf("setting a missing threshold gave %v", err)
+	}
+}
+
+func TestThresholdsQuoted(t *testing.T) {
+	path := filepath.Join(t.TempDir(), ".ratchet")
+	if err := os.WriteFile(path, []byte("metric: echo 1\nthreshold: \"5\"\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := Thresholds(path); err == nil {
+		t.Error("expected a quoted threshold to be refused")
+	}
+}
@@ -0,0 +1,249 @@
+package count
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/tiernacity/ratchet/internal/executor"
+	"github.com/tiernacity/ratchet/internal/git"
+)
+
+// Format selects what a count writes as its output
+type Format int
+
+const (
+	// Total writes the number of matching lines
+	Total Format = iota
+	// PerFile writes "path<TAB>count" for each file with a matching line
+	PerFile
+	// Lines writes each matching line as "path:line:text"
+	Lines
+)
+
+// binaryProbe is how much of a file is checked for NUL bytes to decide that
+// it is binary and skip it, as git and grep do
+const binaryProbe = 8000
+
+// Counter counts the lines matching any of a set of regular expressions in
+// the files git lists, without running a shell
+type Counter struct {
+	Patterns []string
+	Include  []string // Globs a file must match one of, if any are given
+	Exclude  []string // Globs a file must match none of
+
+	patterns []*regexp.Regexp
+	include  []*regexp.Regexp
+	exclude  []*regexp.Regexp
+}
+
+// New returns a counter, checking the patterns and globs
+func New(patterns []string, include []string, exclude []string) (*Counter, error) {
+	if len(patterns) == 0 {
+		return nil, fmt.Errorf("count requires at least one pattern")
+	}
+	c := &Counter{Patterns: patterns, Include: include, Exclude: exclude}
+	for _, p := range patterns {
+		re, err := regexp.Compile(p)
+		if err != nil {
+			return nil, fmt.Errorf("invalid count pattern '%s': %w", p, err)
+		}
+		c.patterns = append(c.patterns, re)
+	}
+	var err error
+	if c.include, err = compileGlobs(include); err != nil {
+		return nil, err
+	}
+	if c.exclude, err = compileGlobs(exclude); err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+// String describes the count as a command line, which identifies it in
+// reports, the cache and git notes as the command of a metric would
+func (c *Counter) String() string {
+	parts := []string{"ratchet count"}
+	for _, p := range c.Patterns {
+		parts = append(parts, quote(p))
+	}
+	for _, g := range c.Include {
+		parts = append(parts, "--include", quote(g))
+	}
+	for _, g := range c.Exclude {
+		parts = append(parts, "--exclude", quote(g))
+	}
+	return strings.Join(parts, " ")
+}
+
+// Run counts the matching lines in the files under dir, in path order, and
+// writes them in the given format. It stops early if ctx is done.
+func (c *Counter) Run(ctx context.Context, dir string, format Format) (string, error) {
+	if dir == "" {
+		dir = "."
+	}
+	files, err := git.ListFiles(dir)
+	if err != nil {
+		return "", err
+	}
+
+	var out strings.Builder
+	total := 0
+	for _, path := range files {
+		if err := ctx.Err(); err != nil {
+			if errors.Is(err, context.DeadlineExceeded) {
+				return "", fmt.Errorf("count timed out: %w", err)
+			}
+			return "", executor.ErrInterrupted
+		}
+		if !c.selects(path) {
+			continue
+		}
+		data, err := os.ReadFile(filepath.Join(dir, filepath.FromSlash(path)))
+		if err != nil || bytes.IndexByte(data[:min(len(data), binaryProbe)], 0) >= 0 {
+			// Deleted but still tracked, unreadable, or binary
+			continue
+		}
+
+		matches := 0
+		scanner := bufio.NewScanner(bytes.NewReader(data))
+		scanner.Buffer(make([]byte, 0, 64*1024), len(data)+1)
+		for n := 1; scanner.Scan(); n++ {
+			line := strings.TrimSuffix(scanner.Text(), "\r")
+			if !c.matches(line) {
+				continue
+			}
+			matches++
+			if format == Lines {
+				fmt.Fprintf(&out, "%s:%d:%s\n", path, n, line)
+			}
+		}
+		if matches > 0 && format == PerFile {
+			fmt.Fprintf(&out, "%s\t%d\n", path, matches)
+		}
+		total += matches
+	}
+
+	if format == Total {
+		return strconv.Itoa(total), nil
+	}
+	return strings.TrimSpace(out.String()), nil
+}
+
+// selects reports whether the include and exclude globs let path through
+func (c *Counter) selects(path string) bool {
+	if len(c.include) > 0 && !matchAny(c.include, path) {
+		return false
+	}
+	return !matchAny(c.exclude, path)
+}
+
+// matches reports whether line matches any of the patterns
+func (c *Counter) matches(line string) bool {
+	for _, re := range c.patterns {
+		if re.MatchString(line) {
+			return true
+		}
+	}
+	return false
+}
+
+func matchAny(globs []*regexp.Regexp, path string) bool {
+	for _, re := range globs {
+		if re.MatchString(path) {
+			return true
+		}
+	}
+	return false
+}
+
+// compileGlobs converts globs to regular expressions. "*", "?" and character
+// classes do not match "/", "**" matches across directories, and a glob
+// without a "/" matches the file name in any directory, as in .gitignore.
+func compileGlobs(globs []string) ([]*regexp.Regexp, error) {
+	var compiled []*regexp.Regexp
+	for _, glob := range globs {
+		g := strings.TrimPrefix(filepath.ToSlash(glob), "./")
+		var b strings.Builder
+		b.WriteString("^")
+		if !strings.Contains(strings.TrimSuffix(g, "/"), "/") {
+			b.WriteString("(?:.*/)?")
+		}
+		for i := 0; i < len(g); i++ {
+			switch {
+			case strings.HasPrefix(g[i:], "**/"):
+				b.WriteString("(?:.*/)?")
+				i += 2
+			case strings.HasPrefix(g[i:], "**"):
+				b.WriteString(".*")
+				i++
+			case g[i] == '*':
+				b.WriteString("[^/]*")
+			case g[i] == '?':
+				b.WriteString("[^/]")
+			case g[i] == '[' && classEnd(g, i) > 0:
+				// A class such as [abc], [a-z] or [!0-9], which never matches "/"
+				end := classEnd(g, i)
+				members := g[i+1 : end]
+				if members[0] == '!' || members[0] == '^' {
+					b.WriteString("[^/" + regexp.QuoteMeta(members[1:]) + "]")
+				} else {
+					b.WriteString("[" + regexp.QuoteMeta(members) + "]")
+				}
+				i = end
+			default:
+				b.WriteString(regexp.QuoteMeta(g[i : i+1]))
+			}
+		}
+		// A directory matches everything below it
+		if strings.HasSuffix(g, "/") {
+			b.WriteString(".*")
+		} else {
+			b.WriteString("(?:/.*)?")
+		}
+		b.WriteString("$")
+		re, err := regexp.Compile(b.String())
+		if err != nil {
+			return nil, fmt.Errorf("invalid glob '%s': %w", glob, err)
+		}
+		compiled = append(compiled, re)
+	}
+	return compiled, nil
+}
+
+// classEnd returns the index of the "]" closing the character class that
+// starts at g[i], or -1 if it is not a class and "[" is meant literally. The
+// first member may be "]", and a class cannot hold "/".
+func classEnd(g string, i int) int {
+	start := i + 1
+	if start < len(g) && (g[start] == '!' || g[start] == '^') {
+		start++
+	}
+	end := strings.IndexByte(g[min(start+1, len(g)):], ']')
+	if start >= len(g) || end < 0 {
+		return -1
+	}
+	end += start + 1
+	if strings.Contains(g[i:end], "/") {
+		return -1
+	}
+	return end
+}
+
+// quote wraps s in single quotes if it holds anything a shell would split or
+// expand, so the description can be read as a command line
+func quote(s string) string {
+	if s != "" && strings.IndexFunc(s, func(r rune) bool {
+		return !(r == '-' || r == '_' || r == '.' || r == '/' || r >= '0' && r <= '9' || r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z')
+	}) < 0 {
+		return s
+	}
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+}
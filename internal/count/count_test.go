@@ -0,0 +1,136 @@
+package count
+
+import (
+	"context"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+)
+
+func TestCompileGlobs(t *testing.T) {
+	tests := []struct {
+		glob  string
+		path  string
+		match bool
+	}{
+		{"*.go", "main.go", true},
+		{"*.go", "internal/count/count.go", true},
+		{"*.go", "main.go.orig", false},
+		{"*.go", "go", false},
+		{"internal/*.go", "internal/a.go", true},
+		{"internal/*.go", "internal/count/a.go", false},
+		{"internal/*.go", "x/internal/a.go", false},
+		{"internal/**/*.go", "internal/a.go", true},
+		{"internal/**/*.go", "internal/count/deep/a.go", true},
+		{"**/testdata", "a/b/testdata/x.txt", true},
+		{"internal/**", "internal/a/b.go", true},
+		{"internal/**", "internals/a.go", false},
+		{"a?.go", "ab.go", true},
+		{"a?.go", "abc.go", false},
+		{"a?b", "a/b", false},
+		{"vendor/", "vendor/x/y.go", true},
+		{"vendor/", "src/vendor/y.go", true},
+		{"vendor", "vendor/y.go", true},
+		{"vendor", "vendored.go", false},
+		{"./cmd/main.go", "cmd/main.go", true},
+		{"file[0-9].txt", "file7.txt", true},
+		{"file[0-9].txt", "filex.txt", false},
+		{"file[!0-9].txt", "filex.txt", true},
+		{"file[!0-9].txt", "file7.txt", false},
+		{"file[^0-9].txt", "file7.txt", false},
+		{"[]a].txt", "].txt", true},
+		{"a[!x]b", "a/b", false},
+		{"a[.txt", "a[.txt", true},
+		{"a[/]b", "a[/]b", true},
+		{"a.b", "axb", false},
+		{"(x)+.go", "(x)+.go", true},
+	}
+	for _, tt := range tests {
+		globs, err := compileGlobs([]string{tt.glob})
+		if err != nil {
+			t.Errorf("glob %q: %v", tt.glob, err)
+			continue
+		}
+		if got := globs[0].MatchString(tt.path); got != tt.match {
+			t.Errorf("glob %q on %q: got %v, want %v (regexp %s)", tt.glob, tt.path, got, tt.match, globs[0])
+		}
+	}
+}
+
+func TestRun(t *testing.T) {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git not available")
+	}
+	dir := t.TempDir()
+	files := map[string]string{
+		"b.go":           "// TODO one\nx := 1 // todo lower case\n// FIXME two\n",
+		"a/a.go":         "// TODO three\r\n",
+		"a/skip.txt":     "TODO not included\n",
+		"vendor/v.go":    "// TODO excluded\n",
+		"ignored.go":     "// TODO ignored by git\n",
+		".gitignore":     "ignored.go\n",
+		"bin/binary.go":  "TODO\x00binary\n",
+		"c.go":           "nothing here\n",
+		"d/deep/deep.go": "TODO\nTODO\n",
+	}
+	for path, content := range files {
+		path = filepath.Join(dir, filepath.FromSlash(path))
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if out, err := exec.Command("git", "-C", dir, "init", "-q").CombinedOutput(); err != nil {
+		t.Fatalf("git init: %v\n%s", err, out)
+	}
+
+	c, err := New([]string{"TODO", "FIXME"}, []string{"*.go"}, []string{"vendor/"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		format Format
+		want   string
+	}{
+		{Total, "5"},
+		{PerFile, "a/a.go\t1\nb.go\t2\nd/deep/deep.go\t2"},
+		{Lines, "a/a.go:1:// TODO three\nb.go:1:// TODO one\nb.go:3:// FIXME two\nd/deep/deep.go:1:TODO\nd/deep/deep.go:2:TODO"},
+	}
+	for _, tt := range tests {
+		got, err := c.Run(context.Background(), dir, tt.format)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got != tt.want {
+			t.Errorf("format %d: got\n%s\nwant\n%s", tt.format, got, tt.want)
+		}
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := c.Run(ctx, dir, Total); err == nil {
+		t.Error("expected a cancelled count to stop")
+	}
+}
+
+func TestNewRejects(t *testing.T) {
+	if _, err := New(nil, nil, nil); err == nil {
+		t.Error("expected a count without patterns to be rejected")
+	}
+	if _, err := New([]string{"("}, nil, nil); err == nil {
+		t.Error("expected an invalid pattern to be rejected")
+	}
+}
+
+func TestString(t *testing.T) {
+	c, err := New([]string{"TODO", "it's"}, []string{"*.go"}, []string{"vendor/"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := c.String(), `ratchet count TODO 'it'\''s' --include '*.go' --exclude vendor/`; got != want {
+		t.Errorf("got %s, want %s", got, want)
+	}
+}
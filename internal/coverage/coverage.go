@@ -0,0 +1,213 @@
+package coverage
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Profile is line coverage read from a Go cover profile or an LCOV trace
+type Profile struct {
+	files map[string]map[int]bool // Coverable lines of each file, and whether each was run
+
+	// Overall coverage, by statement for Go and by line for LCOV
+	total   int
+	covered int
+}
+
+// Line identifies one line of a source file
+type Line struct {
+	File string
+	Line int
+}
+
+// Parse reads a Go cover profile, which starts with a "mode:" line, or an
+// LCOV trace
+func Parse(data string) (*Profile, error) {
+	trimmed := strings.TrimSpace(data)
+	switch {
+	case strings.HasPrefix(trimmed, "mode:"):
+		return parseGo(trimmed)
+	case strings.HasPrefix(trimmed, "TN:") || strings.HasPrefix(trimmed, "SF:"):
+		return parseLCOV(trimmed)
+	default:
+		return nil, fmt.Errorf("not a Go cover profile or LCOV trace")
+	}
+}
+
+// parseGo reads lines such as "example.com/m/a.go:10.2,12.16 2 1", giving the
+// block's start and end positions, number of statements and run count. A block
+// listed more than once, as when packages are tested together, counts as run
+// if any listing was.
+func parseGo(data string) (*Profile, error) {
+	type block struct {
+		file       string
+		start, end int
+		statements int
+	}
+	blocks := make(map[string]*block)
+	run := make(map[string]bool)
+	var order []string
+
+	lines := strings.Split(data, "\n")
+	for i, line := range lines[1:] {
+		line = strings.TrimSpace(line)
+		if line == "" {
+			continue
+		}
+		invalid := fmt.Errorf("line %d of cover profile is not valid: '%s'", i+2, line)
+		colon := strings.LastIndexByte(line, ':')
+		if colon < 0 {
+			return nil, invalid
+		}
+		fields := strings.Fields(line[colon+1:])
+		if len(fields) != 3 {
+			return nil, invalid
+		}
+		from, to, ok := strings.Cut(fields[0], ",")
+		if !ok {
+			return nil, invalid
+		}
+		start, err1 := strconv.Atoi(strings.Split(from, ".")[0])
+		endLine, endCol, _ := strings.Cut(to, ".")
+		end, err2 := strconv.Atoi(endLine)
+		statements, err3 := strconv.Atoi(fields[1])
+		count, err4 := strconv.Atoi(fields[2])
+		if err1 != nil || err2 != nil || err3 != nil || err4 != nil {
+			return nil, invalid
+		}
+		// A block ending in column 1 stops before the closing brace on its last line
+		if endCol == "1" && end > start {
+			end--
+		}
+
+		key := line[:colon] + ":" + fields[0]
+		if _, seen := blocks[key]; !seen {
+			blocks[key] = &block{file: line[:colon], start: start, end: end, statements: statements}
+			order = append(order, key)
+		}
+		run[key] = run[key] || count > 0
+	}
+
+	p := &Profile{files: make(map[string]map[int]bool)}
+	for _, key := range order {
+		b := blocks[key]
+		p.total += b.statements
+		if run[key] {
+			p.covered += b.statements
+		}
+		if b.statements == 0 {
+			continue
+		}
+		lines := p.fileLines(b.file)
+		for n := b.start; n <= b.end; n++ {
+			lines[n] = lines[n] || run[key]
+		}
+	}
+	return p, nil
+}
+
+// parseLCOV reads the SF (source file) and DA (line, count) records of an
+// LCOV trace
+func parseLCOV(data string) (*Profile, error) {
+	p := &Profile{files: make(map[string]map[int]bool)}
+	var lines map[int]bool
+	for i, line := range strings.Split(data, "\n") {
+		line = strings.TrimSpace(line)
+		switch {
+		case strings.HasPrefix(line, "SF:"):
+			lines = p.fileLines(strings.TrimPrefix(line, "SF:"))
+		case strings.HasPrefix(line, "DA:"):
+			fields := strings.Split(strings.TrimPrefix(line, "DA:"), ",")
+			if lines == nil || len(fields) < 2 {
+				return nil, fmt.Errorf("line %d of LCOV trace is not valid: '%s'", i+1, line)
+			}
+			n, err1 := strconv.Atoi(fields[0])
+			count, err2 := strconv.ParseFloat(fields[1], 64)
+			if err1 != nil || err2 != nil {
+				return nil, fmt.Errorf("line %d of LCOV trace is not valid: '%s'", i+1, line)
+			}
+			lines[n] = lines[n] || count > 0
+		case line == "end_of_record":
+			lines = nil
+		}
+	}
+
+	for _, lines := range p.files {
+		for _, run := range lines {
+			p.total++
+			if run {
+				p.covered++
+			}
+		}
+	}
+	return p, nil
+}
+
+// fileLines returns the coverable lines of file, adding it if necessary
+func (p *Profile) fileLines(file string) map[int]bool {
+	lines, ok := p.files[file]
+	if !ok {
+		lines = make(map[int]bool)
+		p.files[file] = lines
+	}
+	return lines
+}
+
+// Percent returns the overall coverage as a percentage, 0 if nothing is
+// coverable
+func (p *Profile) Percent() float64 {
+	if p.total == 0 {
+		return 0
+	}
+	return 100 * float64(p.covered) / float64(p.total)
+}
+
+// Changed returns the coverage of the given lines, keyed by path relative to
+// the current directory: how many of them are coverable, and which of those
+// were not run, in file and line order. A profile path matches a changed path
+// if it is the same or ends with it, as import paths in Go profiles and
+// absolute paths in LCOV traces do.
+func (p *Profile) Changed(changed map[string][]int) (coverable int, uncovered []Line) {
+	for path, numbers := range changed {
+		lines := p.match(path)
+		if lines == nil {
+			continue
+		}
+		for _, n := range numbers {
+			run, ok := lines[n]
+			if !ok {
+				continue
+			}
+			coverable++
+			if !run {
+				uncovered = append(uncovered, Line{File: path, Line: n})
+			}
+		}
+	}
+	sort.Slice(uncovered, func(i, j int) bool {
+		if uncovered[i].File != uncovered[j].File {
+			return uncovered[i].File < uncovered[j].File
+		}
+		return uncovered[i].Line < uncovered[j].Line
+	})
+	return coverable, uncovered
+}
+
+// match finds the coverable lines of the profile file matching path
+func (p *Profile) match(path string) map[int]bool {
+	if lines, ok := p.files[path]; ok {
+		return lines
+	}
+	var best string
+	for file := range p.files {
+		if strings.HasSuffix(file, "/"+path) && (best == "" || len(file) < len(best)) {
+			best = file
+		}
+	}
+	if best == "" {
+		return nil
+	}
+	return p.files[best]
+}
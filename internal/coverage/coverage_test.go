@@ -0,0 +1,153 @@
+package coverage
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		name    string
+		data    string
+		percent float64
+		files   map[string]map[int]bool
+		wantErr string
+	}{
+		{
+			name:    "go profile",
+			data:    "mode: set\nm/a.go:3.14,5.2 2 1\nm/a.go:7.2,8.10 2 0\n",
+			percent: 50,
+			files:   map[string]map[int]bool{"m/a.go": {3: true, 4: true, 5: true, 7: false, 8: false}},
+		},
+		{
+			name:    "go block ending in column 1",
+			data:    "mode: count\nm/a.go:3.14,6.1 1 4\n",
+			percent: 100,
+			files:   map[string]map[int]bool{"m/a.go": {3: true, 4: true, 5: true}},
+		},
+		{
+			name:    "go one-line block ending in column 1",
+			data:    "mode: count\nm/a.go:3.1,3.1 1 0\n",
+			percent: 0,
+			files:   map[string]map[int]bool{"m/a.go": {3: false}},
+		},
+		{
+			name:    "go duplicate blocks count once, run if any run",
+			data:    "mode: set\nm/a.go:1.1,2.5 3 0\nm/b.go:1.1,1.5 1 0\nm/a.go:1.1,2.5 3 1\n",
+			percent: 75,
+			files:   map[string]map[int]bool{"m/a.go": {1: true, 2: true}, "m/b.go": {1: false}},
+		},
+		{
+			name:    "go block without statements",
+			data:    "mode: set\nm/a.go:1.1,2.5 0 0\n",
+			percent: 0,
+			files:   map[string]map[int]bool{},
+		},
+		{
+			name:    "go windows path",
+			data:    "mode: set\nC:/src/a.go:1.1,1.5 1 1\n",
+			percent: 100,
+			files:   map[string]map[int]bool{"C:/src/a.go": {1: true}},
+		},
+		{name: "go invalid", data: "mode: set\nm/a.go 1 1\n", wantErr: "line 2 of cover profile is not valid"},
+		{name: "go bad position", data: "mode: set\nm/a.go:x.1,2.1 1 1\n", wantErr: "line 2"},
+		{
+			name:    "lcov",
+			data:    "TN:\nSF:/src/a.js\nDA:1,1\nDA:2,0\nDA:3,0\nend_of_record\nSF:/src/b.js\nDA:1,2\nend_of_record\n",
+			percent: 50,
+			files:   map[string]map[int]bool{"/src/a.js": {1: true, 2: false, 3: false}, "/src/b.js": {1: true}},
+		},
+		{
+			name:    "lcov line listed twice",
+			data:    "SF:a.js\nDA:1,0\nDA:1,3\nend_of_record\n",
+			percent: 100,
+			files:   map[string]map[int]bool{"a.js": {1: true}},
+		},
+		{name: "lcov without SF", data: "TN:\nDA:1,1\nend_of_record\n", wantErr: "line 2 of LCOV trace is not valid"},
+		{name: "lcov DA after end of record", data: "SF:a.js\nend_of_record\nDA:1,1\n", wantErr: "line 3 of LCOV trace"},
+		{name: "lcov bad count", data: "SF:a.js\nDA:1,x\n", wantErr: "line 2"},
+		{name: "unknown format", data: "DA:1,1\n", wantErr: "not a Go cover profile or LCOV trace"},
+		{name: "empty", data: "", wantErr: "not a Go cover profile or LCOV trace"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p, err := Parse(tt.data)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("got error %v, want one containing %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := p.Percent(); got != tt.percent {
+				t.Errorf("percent %g, want %g", got, tt.percent)
+			}
+			if !reflect.DeepEqual(p.files, tt.files) {
+				t.Errorf("lines %v, want %v", p.files, tt.files)
+			}
+		})
+	}
+}
+
+func TestChanged(t *testing.T) {
+	p, err := Parse("mode: set\n" +
+		"example.com/m/pkg/a.go:1.1,3.2 1 1\n" +
+		"example.com/m/pkg/a.go:5.1,6.2 1 0\n" +
+		"example.com/m/xpkg/b.go:1.1,1.2 1 0\n" +
+		"example.com/m/vendor/other/pkg/b.go:1.1,1.2 1 1\n" +
+		"example.com/m/pkg/b.go:1.1,1.2 1 0\n")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name      string
+		changed   map[string][]int
+		coverable int
+		uncovered []Line
+	}{
+		{
+			name:      "suffix of the import path",
+			changed:   map[string][]int{"pkg/a.go": {2, 4, 6, 5}},
+			coverable: 3,
+			uncovered: []Line{{"pkg/a.go", 5}, {"pkg/a.go", 6}},
+		},
+		{
+			name:    "suffix must start at a path element",
+			changed: map[string][]int{"kg/a.go": {1}},
+		},
+		{
+			name:      "shortest match wins",
+			changed:   map[string][]int{"pkg/b.go": {1}},
+			coverable: 1,
+			uncovered: []Line{{"pkg/b.go", 1}},
+		},
+		{
+			name:      "exact path",
+			changed:   map[string][]int{"example.com/m/xpkg/b.go": {1}},
+			coverable: 1,
+			uncovered: []Line{{"example.com/m/xpkg/b.go", 1}},
+		},
+		{
+			name:      "uncovered in file and line order",
+			changed:   map[string][]int{"xpkg/b.go": {1}, "pkg/a.go": {6}},
+			coverable: 2,
+			uncovered: []Line{{"pkg/a.go", 6}, {"xpkg/b.go", 1}},
+		},
+		{
+			name:    "file not in the profile",
+			changed: map[string][]int{"c.go": {1}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			coverable, uncovered := p.Changed(tt.changed)
+			if coverable != tt.coverable || !reflect.DeepEqual(uncovered, tt.uncovered) {
+				t.Errorf("got %d coverable, uncovered %v; want %d, %v", coverable, uncovered, tt.coverable, tt.uncovered)
+			}
+		})
+	}
+}
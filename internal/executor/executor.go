@@ -3,6 +3,7 @@ package executor
 import (
 	"bytes"
 	"context"
+	"errors"
 	"fmt"
 	"os"
 	"os/exec"
@@ -11,10 +12,25 @@ import (
 	"syscall"
 )
 
+// Output holds what a command wrote, with surrounding whitespace trimmed
+type Output struct {
+	Stdout string
+	Stderr string
+}
+
 // Execute runs a command and returns its stdout output
 func Execute(command string, workingDir string) (string, error) {
+	output, err := ExecuteContext(context.Background(), command, workingDir)
+	return output.Stdout, err
+}
+
+// ExecuteContext runs a command and returns its stdout and stderr, killing its
+// process group if ctx is cancelled before the command completes. If ctx
+// reaches its deadline the error wraps context.DeadlineExceeded. On failure
+// the captured stderr is returned as well as being included in the error.
+func ExecuteContext(parent context.Context, command string, workingDir string) (Output, error) {
 	// Create a context that can be cancelled
-	ctx, cancel := context.WithCancel(context.Background())
+	ctx, cancel := context.WithCancel(parent)
 	defer cancel()
 
 	// Set up signal handling to cancel context on interrupt
@@ -47,6 +63,12 @@ func Execute(command string, workingDir string) (string, error) {
 	// Set process group so we can kill child processes (Unix only)
 	setProcAttr(cmd)
 
+	// Kill the whole process group, not just the shell, when the context is cancelled
+	cmd.Cancel = func() error {
+		killProcess(cmd)
+		return nil
+	}
+
 	// Capture stdout and stderr
 	var stdout, stderr bytes.Buffer
 	cmd.Stdout = &stdout
@@ -55,7 +77,7 @@ func Execute(command string, workingDir string) (string, error) {
 	// Start the command
 	err := cmd.Start()
 	if err != nil {
-		return "", fmt.Errorf("failed to start command: %w", err)
+		return Output{}, fmt.Errorf("failed to start command: %w", err)
 	}
 
 	// Wait for command to complete or context to be cancelled
@@ -66,21 +88,43 @@ func Execute(command string, workingDir string) (string, error) {
 
 	select {
 	case err := <-done:
+		stderrStr := strings.TrimSpace(stderr.String())
+		if err != nil && ctx.Err() != nil {
+			// The command was killed because the context was cancelled
+			return Output{Stdout: strings.TrimSpace(stdout.String()), Stderr: stderrStr}, interrupted(ctx)
+		}
 		if err != nil {
 			// Include stderr in error message for debugging
-			stderrStr := strings.TrimSpace(stderr.String())
 			if stderrStr != "" {
-				return "", fmt.Errorf("command failed: %w\nstderr: %s", err, stderrStr)
+				return Output{Stderr: stderrStr}, fmt.Errorf("command failed: %w\nstderr: %s", err, stderrStr)
 			}
-			return "", fmt.Errorf("command failed: %w", err)
+			return Output{}, fmt.Errorf("command failed: %w", err)
 		}
 	case <-ctx.Done():
-		// Context was cancelled (likely due to signal), kill the process group
-		killProcess(cmd)
+		// Context was cancelled (likely due to signal), the process group is
+		// killed via cmd.Cancel
 		<-done // Wait for cmd.Wait() to return
-		return "", fmt.Errorf("command interrupted")
+		return Output{
+			Stdout: strings.TrimSpace(stdout.String()),
+			Stderr: strings.TrimSpace(stderr.String()),
+		}, interrupted(ctx)
 	}
 
-	// Return stdout output
-	return strings.TrimSpace(stdout.String()), nil
+	// Return stdout and stderr output
+	return Output{
+		Stdout: strings.TrimSpace(stdout.String()),
+		Stderr: strings.TrimSpace(stderr.String()),
+	}, nil
+}
+
+// ErrInterrupted is returned for a command killed because ratchet was
+// interrupted, as opposed to timing out
+var ErrInterrupted = errors.New("command interrupted")
+
+// interrupted returns the error for a command killed because ctx was done
+func interrupted(ctx context.Context) error {
+	if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+		return fmt.Errorf("command timed out: %w", context.DeadlineExceeded)
+	}
+	return ErrInterrupted
 }
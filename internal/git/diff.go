@@ -0,0 +1,92 @@
+package git
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"os"
+	"os/exec"
+	"strconv"
+	"strings"
+)
+
+// ChangedLines returns the lines of each file in the working copy that were
+// added or modified since commit, keyed by path relative to the current
+// directory. Untracked files count as changed throughout.
+func ChangedLines(commit string) (map[string][]int, error) {
+	cmd := exec.Command("git", "diff", "--relative", "--unified=0", "--no-color", "--no-ext-diff", "--no-renames", commit, "--")
+	output, err := cmd.Output()
+	if err != nil {
+		return nil, fmt.Errorf("failed to diff against %s: %w", commit, err)
+	}
+
+	changed := make(map[string][]int)
+	file := ""
+	scanner := bufio.NewScanner(bytes.NewReader(output))
+	scanner.Buffer(make([]byte, 0, 64*1024), 16*1024*1024)
+	for scanner.Scan() {
+		line := scanner.Text()
+		switch {
+		case strings.HasPrefix(line, "+++ "):
+			file = ""
+			if path := strings.TrimPrefix(line, "+++ "); path != "/dev/null" {
+				file = strings.TrimPrefix(path, "b/")
+			}
+		case strings.HasPrefix(line, "@@ ") && file != "":
+			start, count, err := parseHunk(line)
+			if err != nil {
+				return nil, err
+			}
+			for n := start; n < start+count; n++ {
+				changed[file] = append(changed[file], n)
+			}
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read diff against %s: %w", commit, err)
+	}
+
+	cmd = exec.Command("git", "ls-files", "--others", "--exclude-standard")
+	output, err = cmd.Output()
+	if err != nil {
+		return nil, fmt.Errorf("failed to list untracked files: %w", err)
+	}
+	for _, path := range strings.Split(strings.TrimSpace(string(output)), "\n") {
+		if path == "" {
+			continue
+		}
+		data, err := os.ReadFile(path)
+		if err != nil {
+			continue
+		}
+		lines := bytes.Count(data, []byte("\n"))
+		if len(data) > 0 && data[len(data)-1] != '\n' {
+			lines++
+		}
+		for n := 1; n <= lines; n++ {
+			changed[path] = append(changed[path], n)
+		}
+	}
+	return changed, nil
+}
+
+// parseHunk returns the range of new lines in a hunk header such as
+// "@@ -10,2 +12,3 @@ func main() {"
+func parseHunk(header string) (start int, count int, err error) {
+	fields := strings.Fields(header)
+	if len(fields) < 3 || !strings.HasPrefix(fields[2], "+") {
+		return 0, 0, fmt.Errorf("unexpected diff hunk header '%s'", header)
+	}
+	spec := strings.TrimPrefix(fields[2], "+")
+	count = 1
+	if s, c, found := strings.Cut(spec, ","); found {
+		spec = s
+		if count, err = strconv.Atoi(c); err != nil {
+			return 0, 0, fmt.Errorf("unexpected diff hunk header '%s'", header)
+		}
+	}
+	if start, err = strconv.Atoi(spec); err != nil {
+		return 0, 0, fmt.Errorf("unexpected diff hunk header '%s'", header)
+	}
+	return start, count, nil
+}
@@ -5,6 +5,7 @@ import (
 	"os"
 	"os/exec"
 	"path/filepath"
+	"sort"
 	"strings"
 	"time"
 )
@@ -26,6 +27,80 @@ func GetCurrentBranch() (string, error) {
 	return strings.TrimSpace(string(output)), nil
 }
 
+// HeadCommit returns the commit SHA that HEAD points to
+func HeadCommit() (string, error) {
+	cmd := exec.Command("git", "rev-parse", "HEAD")
+	output, err := cmd.Output()
+	if err != nil {
+		return "", fmt.Errorf("failed to resolve HEAD: %w", err)
+	}
+	return strings.TrimSpace(string(output)), nil
+}
+
+// HasUncommittedChanges reports whether tracked files in the working copy
+// differ from HEAD
+func HasUncommittedChanges() (bool, error) {
+	cmd := exec.Command("git", "status", "--porcelain", "--untracked-files=no")
+	output, err := cmd.Output()
+	if err != nil {
+		return false, fmt.Errorf("failed to get working copy status: %w", err)
+	}
+	return strings.TrimSpace(string(output)) != "", nil
+}
+
+// ChangedFiles lists the files, relative to the repository root, that differ
+// from HEAD or are untracked and not ignored, apart from except (relative to
+// the current directory)
+func ChangedFiles(except string) ([]string, error) {
+	cmd := exec.Command("git", "status", "--porcelain", "--untracked-files=all", "--", ":/", ":(exclude)"+except)
+	output, err := cmd.Output()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get working copy status: %w", err)
+	}
+	var files []string
+	for _, line := range strings.Split(string(output), "\n") {
+		if len(line) > 3 {
+			files = append(files, line[3:])
+		}
+	}
+	return files, nil
+}
+
+// ListFiles lists the files under dir that git tracks or would track, leaving
+// out those ignored by .gitignore, sorted and relative to dir with forward
+// slashes on every platform
+func ListFiles(dir string) ([]string, error) {
+	cmd := exec.Command("git", "ls-files", "-z", "--cached", "--others", "--exclude-standard")
+	cmd.Dir = dir
+	output, err := cmd.Output()
+	if err != nil {
+		return nil, fmt.Errorf("failed to list files: %w", err)
+	}
+	seen := make(map[string]bool)
+	var files []string
+	for _, path := range strings.Split(string(output), "\x00") {
+		if path != "" && !seen[path] {
+			seen[path] = true
+			files = append(files, path)
+		}
+	}
+	sort.Strings(files)
+	return files, nil
+}
+
+// CommitFile commits the current contents of a single file, leaving any other
+// staged changes staged
+func CommitFile(path string, message string) error {
+	if output, err := exec.Command("git", "add", "--", path).CombinedOutput(); err != nil {
+		return fmt.Errorf("failed to stage %s: %s", path, strings.TrimSpace(string(output)))
+	}
+	cmd := exec.Command("git", "commit", "--quiet", "--message", message, "--only", "--", path)
+	if output, err := cmd.CombinedOutput(); err != nil {
+		return fmt.Errorf("failed to commit %s: %s", path, strings.TrimSpace(string(output)))
+	}
+	return nil
+}
+
 // EnsureBranchExists checks if a branch exists locally, and fetches it if not
 func EnsureBranchExists(branch string) error {
 	// Check if branch exists locally
@@ -60,6 +135,66 @@ func EnsureBranchExists(branch string) error {
 	return nil
 }
 
+// resolveBranchRef returns branch if it exists locally, or its origin/
+// counterpart otherwise
+func resolveBranchRef(branch string) string {
+	if strings.HasPrefix(branch, "origin/") {
+		return branch
+	}
+
+	// Check if local branch exists
+	cmd := exec.Command("git", "rev-parse", "--verify", branch)
+	if err := cmd.Run(); err != nil {
+		// Use remote branch
+		return "origin/" + branch
+	}
+	return branch
+}
+
+// ResolveCommit returns the commit SHA that a branch or ref points to, using
+// the same local-then-remote lookup as CreateWorktree
+func ResolveCommit(branch string) (string, error) {
+	ref := resolveBranchRef(branch)
+	cmd := exec.Command("git", "rev-parse", "--verify", ref+"^{commit}")
+	output, err := cmd.Output()
+	if err != nil {
+		return "", fmt.Errorf("failed to resolve %s to a commit: %w", branch, err)
+	}
+	return strings.TrimSpace(string(output)), nil
+}
+
+// ShowFile returns the contents of path, relative to the current directory,
+// as committed at ref, and whether it exists there. The ref is looked up, and
+// fetched if necessary, as a base branch is.
+func ShowFile(ref string, path string) ([]byte, bool, error) {
+	if err := EnsureBranchExists(ref); err != nil {
+		return nil, false, err
+	}
+	commit, err := ResolveCommit(ref)
+	if err != nil {
+		return nil, false, err
+	}
+	if filepath.IsAbs(path) {
+		wd, err := os.Getwd()
+		if err != nil {
+			return nil, false, fmt.Errorf("failed to get working directory: %w", err)
+		}
+		if path, err = filepath.Rel(wd, path); err != nil {
+			return nil, false, fmt.Errorf("%s is not in the repository: %w", path, err)
+		}
+	}
+
+	object := commit + ":./" + filepath.ToSlash(path)
+	if err := exec.Command("git", "cat-file", "-e", object).Run(); err != nil {
+		return nil, false, nil
+	}
+	output, err := exec.Command("git", "show", object).Output()
+	if err != nil {
+		return nil, false, fmt.Errorf("failed to read %s at %s: %w", path, ref, err)
+	}
+	return output, true, nil
+}
+
 // CreateWorktree creates a temporary git worktree for the specified branch
 func CreateWorktree(branch string) (string, func(), error) {
 	// Determine temp directory
@@ -72,15 +207,7 @@ func CreateWorktree(branch string) (string, func(), error) {
 	worktreeDir := filepath.Join(tempDir, fmt.Sprintf("ratchet-worktree-%d-%d", os.Getpid(), time.Now().UnixNano()))
 
 	// Resolve branch reference
-	branchRef := branch
-	if !strings.HasPrefix(branch, "origin/") {
-		// Check if local branch exists
-		cmd := exec.Command("git", "rev-parse", "--verify", branch)
-		if err := cmd.Run(); err != nil {
-			// Use remote branch
-			branchRef = "origin/" + branch
-		}
-	}
+	branchRef := resolveBranchRef(branch)
 
 	// Create worktree
 	// First try without --force
@@ -115,3 +242,176 @@ func CreateWorktree(branch string) (string, func(), error) {
 
 	return worktreeDir, cleanup, nil
 }
+
+// deepenSteps are the amounts by which a shallow clone is deepened, in turn,
+// while looking for a merge-base before fetching the full history
+var deepenSteps = []int{50, 200, 1000}
+
+// MergeBase returns the best common ancestor of HEAD and a branch or ref. In
+// a shallow clone the history is deepened until the ancestor is found.
+func MergeBase(branch string) (string, error) {
+	ref := resolveBranchRef(branch)
+	if commit, err := mergeBase(ref); err == nil {
+		return commit, nil
+	}
+	if !isShallow() {
+		return "", fmt.Errorf("no common ancestor of HEAD and %s", branch)
+	}
+
+	for _, depth := range deepenSteps {
+		cmd := exec.Command("git", "fetch", fmt.Sprintf("--deepen=%d", depth), "origin")
+		if output, err := cmd.CombinedOutput(); err != nil {
+			return "", fmt.Errorf("failed to deepen history: %w\nOutput: %s", err, output)
+		}
+		if commit, err := mergeBase(ref); err == nil {
+			return commit, nil
+		}
+	}
+
+	cmd := exec.Command("git", "fetch", "--unshallow", "origin")
+	if output, err := cmd.CombinedOutput(); err != nil {
+		return "", fmt.Errorf("failed to fetch full history: %w\nOutput: %s", err, output)
+	}
+	commit, err := mergeBase(ref)
+	if err != nil {
+		return "", fmt.Errorf("no common ancestor of HEAD and %s", branch)
+	}
+	return commit, nil
+}
+
+// mergeBase runs git merge-base for HEAD and ref
+func mergeBase(ref string) (string, error) {
+	cmd := exec.Command("git", "merge-base", "HEAD", ref)
+	output, err := cmd.Output()
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(string(output)), nil
+}
+
+// isShallow reports whether the repository is a shallow clone
+func isShallow() bool {
+	cmd := exec.Command("git", "rev-parse", "--is-shallow-repository")
+	output, err := cmd.Output()
+	return err == nil && strings.TrimSpace(string(output)) == "true"
+}
+
+// Commit describes one commit in a listing
+type Commit struct {
+	SHA     string
+	Date    time.Time // Committer date
+	Subject string
+	Parents []string // Parent SHAs, first parent first
+}
+
+// ListCommits returns the commits in a revision range such as main~200..main,
+// newest first. An empty range lists the history of HEAD; since, if set,
+// limits the listing to commits more recent than a date git understands. With
+// firstParent only the first parent of each merge is followed, so commits on
+// merged branches are left out and the listing is the branch's own history.
+func ListCommits(revRange string, since string, firstParent bool) ([]Commit, error) {
+	if revRange == "" {
+		revRange = "HEAD"
+	}
+	args := []string{}
+	if since != "" {
+		args = append(args, "--since="+since)
+	}
+	if firstParent {
+		args = append(args, "--first-parent")
+	}
+	return logCommits(revRange, append(args, revRange)...)
+}
+
+// FirstParentPath returns the commits on the first-parent history of bad that
+// are descendants of good, oldest first, ending with bad itself. The oldest
+// commit's first parent is good if good is on that history; otherwise the
+// oldest is a merge that brought good in through another parent.
+func FirstParentPath(good string, bad string) ([]Commit, error) {
+	return logCommits(good+".."+bad, "--first-parent", "--ancestry-path", "--reverse", good+".."+bad)
+}
+
+// CommonAncestor returns the best common ancestor of two commits
+func CommonAncestor(a string, b string) (string, error) {
+	cmd := exec.Command("git", "merge-base", a, b)
+	output, err := cmd.Output()
+	if err != nil {
+		return "", fmt.Errorf("no common ancestor of %s and %s", a, b)
+	}
+	return strings.TrimSpace(string(output)), nil
+}
+
+// LookupCommit describes the commit that a ref points to
+func LookupCommit(ref string) (Commit, error) {
+	commits, err := logCommits(ref, "-1", ref)
+	if err != nil {
+		return Commit{}, err
+	}
+	if len(commits) == 0 {
+		return Commit{}, fmt.Errorf("commit %s not found", ref)
+	}
+	return commits[0], nil
+}
+
+// Trailer is the value of a trailer, such as "Signed-off-by", on a commit
+type Trailer struct {
+	Commit string
+	Value  string
+}
+
+// Trailers returns the values of the trailer named key, matched as git does
+// regardless of case, on the commits in from..HEAD, newest first
+func Trailers(from string, key string) ([]Trailer, error) {
+	format := "--format=%H%n%(trailers:key=" + key + ",valueonly,unfold)%x00"
+	cmd := exec.Command("git", "log", format, from+"..HEAD", "--")
+	var stderr strings.Builder
+	cmd.Stderr = &stderr
+	output, err := cmd.Output()
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %s trailers since %s: %w\nOutput: %s", key, from, err, stderr.String())
+	}
+
+	var trailers []Trailer
+	for _, record := range strings.Split(string(output), "\x00") {
+		lines := strings.Split(strings.TrimSpace(record), "\n")
+		for _, value := range lines[1:] {
+			if value = strings.TrimSpace(value); value != "" {
+				trailers = append(trailers, Trailer{Commit: lines[0], Value: value})
+			}
+		}
+	}
+	return trailers, nil
+}
+
+// IsAncestor reports whether ancestor is an ancestor of (or the same as) commit
+func IsAncestor(ancestor string, commit string) bool {
+	cmd := exec.Command("git", "merge-base", "--is-ancestor", ancestor, commit)
+	return cmd.Run() == nil
+}
+
+// logCommits runs git log with args and parses the commits it lists. what
+// names the listing in error messages.
+func logCommits(what string, args ...string) ([]Commit, error) {
+	args = append([]string{"log", "--format=%H%x09%cI%x09%P%x09%s"}, args...)
+	cmd := exec.Command("git", append(args, "--")...)
+	var stderr strings.Builder
+	cmd.Stderr = &stderr
+	output, err := cmd.Output()
+	if err != nil {
+		return nil, fmt.Errorf("failed to list commits in %s: %w\nOutput: %s", what, err, stderr.String())
+	}
+
+	var commits []Commit
+	for _, line := range strings.Split(strings.TrimSpace(string(output)), "\n") {
+		fields := strings.SplitN(line, "\t", 4)
+		if len(fields) < 4 {
+			continue
+		}
+		date, err := time.Parse(time.RFC3339, fields[1])
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse date of commit %s: %w", fields[0], err)
+		}
+		commits = append(commits, Commit{SHA: fields[0], Date: date, Subject: fields[3], Parents: strings.Fields(fields[2])})
+	}
+	return commits, nil
+}
@@ -0,0 +1,87 @@
+package git
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestListCommitsFirstParent(t *testing.T) {
+	_, repo, _ := setupRemote(t)
+	commit := func(file string, message string) {
+		t.Helper()
+		if err := os.WriteFile(filepath.Join(repo, file), []byte(message+"\n"), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		run(t, repo, "add", file)
+		run(t, repo, "commit", "-q", "-m", message)
+	}
+	run(t, repo, "checkout", "-q", "-b", "side")
+	commit("b", "b1")
+	commit("b", "b2")
+	run(t, repo, "checkout", "-q", "main")
+	commit("a", "a1")
+	run(t, repo, "merge", "-q", "--no-edit", "-m", "M", "side")
+	chdir(t, repo)
+
+	subjects := func(commits []Commit) []string {
+		var s []string
+		for _, c := range commits {
+			s = append(s, c.Subject)
+		}
+		return s
+	}
+
+	commits, err := ListCommits("main~2..main", "", true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := subjects(commits); len(got) != 2 || got[0] != "M" || got[1] != "a1" {
+		t.Errorf("first-parent listing %v, want [M a1]", got)
+	}
+	if len(commits[0].Parents) != 2 || len(commits[1].Parents) != 1 {
+		t.Errorf("parents %v and %v, want two for the merge and one for a1", commits[0].Parents, commits[1].Parents)
+	}
+
+	commits, err = ListCommits("main~2..main", "", false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := subjects(commits); len(got) != 4 {
+		t.Errorf("full listing %v, want the merge, a1, b1 and b2", got)
+	}
+}
+
+func TestTrailers(t *testing.T) {
+	_, repo, base := setupRemote(t)
+	commit := func(message string) string {
+		t.Helper()
+		run(t, repo, "commit", "-q", "--allow-empty", "-m", message)
+		return run(t, repo, "rev-parse", "HEAD")
+	}
+	first := commit("Add vendored code\n\nRatchet-Allow: todo-count \"vendored libfoo\"\nSigned-off-by: test <test@example.com>")
+	commit("No trailers\n\nRatchet-Allow: in the body is not a trailer\n\nJust text.")
+	second := commit("Loosen two\n\nratchet-allow:   lint   \nRATCHET-ALLOW: \"go vet ./...\"\nRatchet-Allowed: other")
+	chdir(t, repo)
+
+	trailers, err := Trailers(base, "Ratchet-Allow")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []Trailer{
+		{Commit: second, Value: "lint"},
+		{Commit: second, Value: `"go vet ./..."`},
+		{Commit: first, Value: `todo-count "vendored libfoo"`},
+	}
+	if !reflect.DeepEqual(trailers, want) {
+		t.Errorf("got trailers %+v, want %+v", trailers, want)
+	}
+
+	if trailers, err := Trailers("HEAD", "Ratchet-Allow"); err != nil || len(trailers) > 0 {
+		t.Errorf("got %v and error %v for an empty range, want neither", trailers, err)
+	}
+	if _, err := Trailers("no-such-ref", "Ratchet-Allow"); err == nil {
+		t.Error("expected an error for an unknown ref")
+	}
+}
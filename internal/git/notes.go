@@ -0,0 +1,117 @@
+package git
+
+import (
+	"bytes"
+	"fmt"
+	"os/exec"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// NotesRef is the notes namespace that ratchet records metric values in. Each
+// note holds one "name=value" line per metric, so that notes from different
+// clones can be combined with the union merge strategy.
+const NotesRef = "refs/notes/ratchet"
+
+// ReadNote returns the metric values recorded on commit, keyed by metric name.
+// A commit without a note yields an empty map.
+func ReadNote(commit string) (map[string]float64, error) {
+	values := make(map[string]float64)
+
+	cmd := exec.Command("git", "notes", "--ref="+NotesRef, "show", commit)
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	output, err := cmd.Output()
+	if err != nil {
+		if strings.Contains(stderr.String(), "no note found") {
+			return values, nil
+		}
+		return nil, fmt.Errorf("failed to read note for %s: %w\nOutput: %s", commit, err, stderr.String())
+	}
+
+	// Later lines win, so that values appended by a union merge take precedence
+	for _, line := range strings.Split(string(output), "\n") {
+		idx := strings.LastIndex(line, "=")
+		if idx <= 0 {
+			continue
+		}
+		value, err := strconv.ParseFloat(strings.TrimSpace(line[idx+1:]), 64)
+		if err != nil {
+			continue
+		}
+		values[line[:idx]] = value
+	}
+	return values, nil
+}
+
+// WriteNote records value for the named metric on commit, keeping the values
+// already recorded there for other metrics
+func WriteNote(commit string, name string, value float64) error {
+	if name == "" || strings.ContainsAny(name, "\r\n") {
+		return fmt.Errorf("cannot record a note for metric name %q", name)
+	}
+
+	values, err := ReadNote(commit)
+	if err != nil {
+		return err
+	}
+	values[name] = value
+
+	names := make([]string, 0, len(values))
+	for n := range values {
+		names = append(names, n)
+	}
+	sort.Strings(names)
+
+	var note strings.Builder
+	for _, n := range names {
+		fmt.Fprintf(&note, "%s=%s\n", n, strconv.FormatFloat(values[n], 'g', -1, 64))
+	}
+
+	cmd := exec.Command("git", "notes", "--ref="+NotesRef, "add", "-f", "-F", "-", commit)
+	cmd.Stdin = strings.NewReader(note.String())
+	if output, err := cmd.CombinedOutput(); err != nil {
+		return fmt.Errorf("failed to write note for %s: %w\nOutput: %s", commit, err, output)
+	}
+	return nil
+}
+
+// FetchNotes fetches the ratchet notes from remote and merges them into the
+// local notes. A remote without any ratchet notes is not an error.
+func FetchNotes(remote string) error {
+	remoteRef := "refs/notes/remotes/" + remote + "/ratchet"
+
+	cmd := exec.Command("git", "fetch", remote, "+"+NotesRef+":"+remoteRef)
+	if output, err := cmd.CombinedOutput(); err != nil {
+		if strings.Contains(string(output), "couldn't find remote ref") {
+			return nil
+		}
+		return fmt.Errorf("failed to fetch notes from %s: %w\nOutput: %s", remote, err, output)
+	}
+
+	// Adopt the remote notes directly if there are none locally yet
+	cmd = exec.Command("git", "rev-parse", "--verify", "--quiet", NotesRef)
+	if err := cmd.Run(); err != nil {
+		cmd = exec.Command("git", "update-ref", NotesRef, remoteRef)
+		if output, err := cmd.CombinedOutput(); err != nil {
+			return fmt.Errorf("failed to update %s: %w\nOutput: %s", NotesRef, err, output)
+		}
+		return nil
+	}
+
+	cmd = exec.Command("git", "notes", "--ref="+NotesRef, "merge", "--quiet", "--strategy=union", remoteRef)
+	if output, err := cmd.CombinedOutput(); err != nil {
+		return fmt.Errorf("failed to merge notes from %s: %w\nOutput: %s", remote, err, output)
+	}
+	return nil
+}
+
+// PushNotes pushes the ratchet notes to remote
+func PushNotes(remote string) error {
+	cmd := exec.Command("git", "push", remote, NotesRef+":"+NotesRef)
+	if output, err := cmd.CombinedOutput(); err != nil {
+		return fmt.Errorf("failed to push notes to %s: %w\nOutput: %s", remote, err, output)
+	}
+	return nil
+}
@@ -0,0 +1,151 @@
+package git
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// chdir makes dir the current directory until the end of the test
+func chdir(t *testing.T, dir string) {
+	t.Helper()
+	wd, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chdir(dir); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { os.Chdir(wd) })
+}
+
+// run runs git in dir, failing the test if it fails
+func run(t *testing.T, dir string, args ...string) string {
+	t.Helper()
+	cmd := exec.Command("git", args...)
+	cmd.Dir = dir
+	out, err := cmd.CombinedOutput()
+	if err != nil {
+		t.Fatalf("git %v: %v\n%s", args, err, out)
+	}
+	return strings.TrimSpace(string(out))
+}
+
+// setupRemote creates a bare repository holding one commit on main, and a
+// clone of it, returning their paths and the commit
+func setupRemote(t *testing.T) (remote string, clone string, commit string) {
+	t.Helper()
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git not available")
+	}
+	t.Setenv("GIT_AUTHOR_NAME", "test")
+	t.Setenv("GIT_AUTHOR_EMAIL", "test@example.com")
+	t.Setenv("GIT_COMMITTER_NAME", "test")
+	t.Setenv("GIT_COMMITTER_EMAIL", "test@example.com")
+
+	root := t.TempDir()
+	remote = filepath.Join(root, "origin.git")
+	clone = filepath.Join(root, "a")
+	run(t, root, "init", "-q", "--bare", "-b", "main", remote)
+	run(t, root, "clone", "-q", remote, clone)
+	if err := os.WriteFile(filepath.Join(clone, "README"), []byte("test\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	run(t, clone, "add", ".")
+	run(t, clone, "commit", "-q", "-m", "init")
+	run(t, clone, "push", "-q", "origin", "HEAD:main")
+	return remote, clone, run(t, clone, "rev-parse", "HEAD")
+}
+
+func TestNotesTravelThroughBareRemote(t *testing.T) {
+	remote, first, commit := setupRemote(t)
+	second := filepath.Join(filepath.Dir(remote), "b")
+	run(t, filepath.Dir(remote), "clone", "-q", remote, second)
+
+	// A remote without notes yet is not an error
+	chdir(t, second)
+	if err := FetchNotes("origin"); err != nil {
+		t.Fatalf("FetchNotes before any push: %v", err)
+	}
+
+	// The first clone records one metric and pushes it
+	chdir(t, first)
+	if err := WriteNote(commit, "todos", 3); err != nil {
+		t.Fatal(err)
+	}
+	if err := PushNotes("origin"); err != nil {
+		t.Fatal(err)
+	}
+
+	// The second clone records another metric on the same commit, then
+	// fetches, so both notes must be combined by the union merge
+	chdir(t, second)
+	if err := WriteNote(commit, "coverage", 81.5); err != nil {
+		t.Fatal(err)
+	}
+	if err := FetchNotes("origin"); err != nil {
+		t.Fatal(err)
+	}
+	values, err := ReadNote(commit)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(values) != 2 || values["todos"] != 3 || values["coverage"] != 81.5 {
+		t.Errorf("after merge got %v, want todos=3 and coverage=81.5", values)
+	}
+
+	// Pushing the merged notes lets the first clone adopt them
+	if err := PushNotes("origin"); err != nil {
+		t.Fatal(err)
+	}
+	chdir(t, first)
+	if err := FetchNotes("origin"); err != nil {
+		t.Fatal(err)
+	}
+	if values, err = ReadNote(commit); err != nil {
+		t.Fatal(err)
+	}
+	if values["todos"] != 3 || values["coverage"] != 81.5 {
+		t.Errorf("first clone got %v after fetching, want todos=3 and coverage=81.5", values)
+	}
+}
+
+func TestFetchNotesAdoptsRemoteNotes(t *testing.T) {
+	remote, first, commit := setupRemote(t)
+	chdir(t, first)
+	if err := WriteNote(commit, "todos", 3); err != nil {
+		t.Fatal(err)
+	}
+	if err := PushNotes("origin"); err != nil {
+		t.Fatal(err)
+	}
+
+	// A fresh clone has no local notes, so the remote ones are taken as they are
+	second := filepath.Join(filepath.Dir(remote), "b")
+	run(t, filepath.Dir(remote), "clone", "-q", remote, second)
+	chdir(t, second)
+	if err := FetchNotes("origin"); err != nil {
+		t.Fatal(err)
+	}
+	values, err := ReadNote(commit)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if values["todos"] != 3 {
+		t.Errorf("got %v, want todos=3", values)
+	}
+}
+
+func TestReadNoteWithoutNote(t *testing.T) {
+	_, clone, commit := setupRemote(t)
+	chdir(t, clone)
+	values, err := ReadNote(commit)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(values) != 0 {
+		t.Errorf("got %v, want no values", values)
+	}
+}
@@ -0,0 +1,218 @@
+package parser
+
+import (
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// Mode selects how the metric is extracted from command output
+type Mode int
+
+const (
+	// Whole requires the output to be exactly one number
+	Whole Mode = iota
+	// Regex takes the first capture group of a regular expression
+	Regex
+	// JSONPath selects a value from JSON output, e.g. $.totals.percent
+	JSONPath
+	// LastNumber takes the last number on the last non-empty line
+	LastNumber
+	// KeyValue takes the value of a key from key=value lines
+	KeyValue
+)
+
+// snippetLength is the most output quoted in an error message
+const snippetLength = 200
+
+var numberPattern = regexp.MustCompile(`[-+]?(?:\d+\.?\d*|\.\d+)(?:[eE][-+]?\d+)?`)
+
+// Extractor finds the metric in the output of a metric command
+type Extractor struct {
+	Mode Mode
+	Expr string // Regular expression, selector or key, depending on Mode
+
+	re   *regexp.Regexp // Compiled Expr in Regex mode
+	path []pathSegment  // Parsed Expr in JSONPath mode
+}
+
+// pathSegment is one step of a JSONPath selector, either a key or an index
+type pathSegment struct {
+	key   string
+	index int
+	isKey bool
+}
+
+// NewExtractor returns an extractor for the given mode, checking that expr is
+// valid for it
+func NewExtractor(mode Mode, expr string) (Extractor, error) {
+	e := Extractor{Mode: mode, Expr: expr}
+	switch mode {
+	case Regex:
+		re, err := regexp.Compile(expr)
+		if err != nil {
+			return Extractor{}, fmt.Errorf("invalid regex '%s': %w", expr, err)
+		}
+		if re.NumSubexp() < 1 {
+			return Extractor{}, fmt.Errorf("regex '%s' must have a capture group", expr)
+		}
+		e.re = re
+	case JSONPath:
+		path, err := parsePath(expr)
+		if err != nil {
+			return Extractor{}, err
+		}
+		e.path = path
+	case KeyValue:
+		if strings.TrimSpace(expr) == "" {
+			return Extractor{}, fmt.Errorf("a key is required")
+		}
+	}
+	return e, nil
+}
+
+// Extract returns the metric found in output. Errors quote the output, cut
+// short if it is long.
+func (e Extractor) Extract(output string) (float64, error) {
+	switch e.Mode {
+	case Regex:
+		match := e.re.FindStringSubmatch(output)
+		if match == nil {
+			return 0, fmt.Errorf("regex '%s' did not match output '%s'", e.Expr, Snippet(output))
+		}
+		return parseExtracted(match[1], output)
+	case JSONPath:
+		return e.extractJSON(output)
+	case LastNumber:
+		lines := strings.Split(strings.TrimSpace(output), "\n")
+		last := lines[len(lines)-1]
+		numbers := numberPattern.FindAllString(last, -1)
+		if len(numbers) == 0 {
+			return 0, fmt.Errorf("no number on the last line of output '%s'", Snippet(output))
+		}
+		return parseExtracted(numbers[len(numbers)-1], output)
+	case KeyValue:
+		value, ok := "", false
+		for _, line := range strings.Split(output, "\n") {
+			k, v, found := strings.Cut(line, "=")
+			if found && strings.TrimSpace(k) == e.Expr {
+				value, ok = v, true
+			}
+		}
+		if !ok {
+			return 0, fmt.Errorf("key '%s' not found in output '%s'", e.Expr, Snippet(output))
+		}
+		return parseExtracted(value, output)
+	default:
+		value, err := ParseNumber(output)
+		if err != nil {
+			return 0, fmt.Errorf("output '%s' is not a valid number", Snippet(output))
+		}
+		return value, nil
+	}
+}
+
+// extractJSON decodes output as JSON and follows the selector to a number, or
+// to a string holding one
+func (e Extractor) extractJSON(output string) (float64, error) {
+	dec := json.NewDecoder(strings.NewReader(output))
+	dec.UseNumber()
+	var value interface{}
+	if err := dec.Decode(&value); err != nil {
+		return 0, fmt.Errorf("output is not valid JSON: '%s'", Snippet(output))
+	}
+
+	for _, seg := range e.path {
+		switch v := value.(type) {
+		case map[string]interface{}:
+			next, ok := v[seg.key]
+			if !seg.isKey || !ok {
+				return 0, fmt.Errorf("selector '%s' did not match output '%s'", e.Expr, Snippet(output))
+			}
+			value = next
+		case []interface{}:
+			if seg.isKey || seg.index >= len(v) {
+				return 0, fmt.Errorf("selector '%s' did not match output '%s'", e.Expr, Snippet(output))
+			}
+			value = v[seg.index]
+		default:
+			return 0, fmt.Errorf("selector '%s' did not match output '%s'", e.Expr, Snippet(output))
+		}
+	}
+
+	switch v := value.(type) {
+	case json.Number:
+		return parseExtracted(v.String(), output)
+	case string:
+		return parseExtracted(v, output)
+	default:
+		return 0, fmt.Errorf("selector '%s' does not select a number in output '%s'", e.Expr, Snippet(output))
+	}
+}
+
+// parseExtracted parses the text picked out of output as a number
+func parseExtracted(text string, output string) (float64, error) {
+	value, err := ParseNumber(text)
+	if err != nil {
+		return 0, fmt.Errorf("extracted '%s' is not a valid number in output '%s'", strings.TrimSpace(text), Snippet(output))
+	}
+	return value, nil
+}
+
+// parsePath parses a selector such as $.totals.lines[0]['percent covered'].
+// The leading $ is optional.
+func parsePath(expr string) ([]pathSegment, error) {
+	invalid := fmt.Errorf("invalid selector '%s': expected a path such as $.totals.percent or $.files[0].lines", expr)
+	s := strings.TrimPrefix(strings.TrimSpace(expr), "$")
+
+	var path []pathSegment
+	for s != "" {
+		switch s[0] {
+		case '.':
+			s = s[1:]
+			end := strings.IndexAny(s, ".[")
+			if end < 0 {
+				end = len(s)
+			}
+			if end == 0 {
+				return nil, invalid
+			}
+			path = append(path, pathSegment{key: s[:end], isKey: true})
+			s = s[end:]
+		case '[':
+			end := strings.IndexByte(s, ']')
+			if end < 0 {
+				return nil, invalid
+			}
+			inner := s[1:end]
+			s = s[end+1:]
+			if len(inner) >= 2 && (inner[0] == '\'' || inner[0] == '"') && inner[len(inner)-1] == inner[0] {
+				path = append(path, pathSegment{key: inner[1 : len(inner)-1], isKey: true})
+				continue
+			}
+			index, err := strconv.Atoi(inner)
+			if err != nil || index < 0 {
+				return nil, invalid
+			}
+			path = append(path, pathSegment{index: index})
+		default:
+			// Allow the first key without a leading dot, e.g. totals.percent
+			if len(path) > 0 {
+				return nil, invalid
+			}
+			s = "." + s
+		}
+	}
+	return path, nil
+}
+
+// Snippet returns output trimmed and cut short enough to quote in a message
+func Snippet(output string) string {
+	output = strings.TrimSpace(output)
+	if len(output) <= snippetLength {
+		return output
+	}
+	return strings.ToValidUTF8(output[:snippetLength], "") + "..."
+}
@@ -0,0 +1,97 @@
+package parser
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestExtract(t *testing.T) {
+	tests := []struct {
+		name    string
+		mode    Mode
+		expr    string
+		output  string
+		want    float64
+		wantErr string
+	}{
+		{name: "whole", mode: Whole, output: " 42\n", want: 42},
+		{name: "whole float", mode: Whole, output: "81.5", want: 81.5},
+		{name: "whole with text", mode: Whole, output: "42 warnings", wantErr: "is not a valid number"},
+		{name: "whole empty", mode: Whole, output: "", wantErr: "is not a valid number"},
+		{name: "regex", mode: Regex, expr: `coverage: ([\d.]+)%`, output: "ok\ncoverage: 81.2% of statements\n", want: 81.2},
+		{name: "regex first match", mode: Regex, expr: `(\d+) errors`, output: "3 errors\n5 errors\n", want: 3},
+		{name: "regex no match", mode: Regex, expr: `(\d+) errors`, output: "clean", wantErr: "did not match"},
+		{name: "regex non-number", mode: Regex, expr: `total: (\w+)`, output: "total: many", wantErr: "extracted 'many' is not a valid number"},
+		{name: "json", mode: JSONPath, expr: "$.totals.percent", output: `{"totals": {"percent": 75.5}}`, want: 75.5},
+		{name: "json without $", mode: JSONPath, expr: "totals.percent", output: `{"totals": {"percent": 75.5}}`, want: 75.5},
+		{name: "json index and quoted key", mode: JSONPath, expr: "$.files[1]['lines covered']", output: `{"files": [{}, {"lines covered": 9}]}`, want: 9},
+		{name: "json string number", mode: JSONPath, expr: "$.n", output: `{"n": "12"}`, want: 12},
+		{name: "json index out of range", mode: JSONPath, expr: "$.a[2]", output: `{"a": [1]}`, wantErr: "did not match"},
+		{name: "json key on array", mode: JSONPath, expr: "$.a.b", output: `{"a": [1]}`, wantErr: "did not match"},
+		{name: "json object selected", mode: JSONPath, expr: "$.a", output: `{"a": {}}`, wantErr: "does not select a number"},
+		{name: "json invalid", mode: JSONPath, expr: "$.a", output: "not json", wantErr: "not valid JSON"},
+		{name: "last number", mode: LastNumber, output: "ran 12 tests\nTotal: 3 of 40\n\n", want: 40},
+		{name: "last number signed exponent", mode: LastNumber, output: "x -1.5e3", want: -1500},
+		{name: "last number leading dot", mode: LastNumber, output: "ratio .25", want: 0.25},
+		{name: "last number none", mode: LastNumber, output: "12\ndone", wantErr: "no number on the last line"},
+		{name: "key value", mode: KeyValue, expr: "errors", output: "warnings=3\nerrors = 2\n", want: 2},
+		{name: "key value last wins", mode: KeyValue, expr: "n", output: "n=1\nn=4\n", want: 4},
+		{name: "key value missing", mode: KeyValue, expr: "errors", output: "warnings=3", wantErr: "key 'errors' not found"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			e, err := NewExtractor(tt.mode, tt.expr)
+			if err != nil {
+				t.Fatal(err)
+			}
+			got, err := e.Extract(tt.output)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("got %g, %v; want an error containing %q", got, err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got != tt.want {
+				t.Errorf("got %g, want %g", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestNewExtractorRejects(t *testing.T) {
+	tests := []struct {
+		mode Mode
+		expr string
+	}{
+		{Regex, `(`},
+		{Regex, `\d+`},
+		{JSONPath, "$."},
+		{JSONPath, "$.a[x]"},
+		{JSONPath, "$.a[-1]"},
+		{JSONPath, "$.a[0"},
+		{JSONPath, "$.a b[0]c"},
+		{KeyValue, " "},
+	}
+	for _, tt := range tests {
+		if _, err := NewExtractor(tt.mode, tt.expr); err == nil {
+			t.Errorf("NewExtractor(%d, %q) succeeded, want an error", tt.mode, tt.expr)
+		}
+	}
+}
+
+func TestSnippet(t *testing.T) {
+	long := strings.Repeat("é", snippetLength)
+	got := Snippet(long)
+	if !strings.HasSuffix(got, "...") || len(got) > snippetLength+3 {
+		t.Errorf("long output not cut short: %d bytes", len(got))
+	}
+	if !strings.HasPrefix(got, "éé") || strings.ContainsRune(got, '�') {
+		t.Errorf("cut left invalid UTF-8: %q", got[:10])
+	}
+	if got := Snippet("  short\n"); got != "short" {
+		t.Errorf("got %q, want the trimmed output", got)
+	}
+}
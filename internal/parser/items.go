@@ -0,0 +1,80 @@
+package parser
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// LineNumbers is the normalization that strips line and column numbers from
+// items such as grep -n or compiler output, e.g. "main.go:12:5: msg" becomes
+// "main.go: msg"
+const LineNumbers = "line-numbers"
+
+var lineNumberPattern = regexp.MustCompile(`:\d+(?::\d+)?:`)
+
+// Normalizer rewrites each item before items are compared, so that changes
+// which do not matter, such as code moving within a file, are not reported.
+// The zero value leaves items unchanged.
+type Normalizer struct {
+	Spec string // LineNumbers, or a regular expression whose matches are removed
+
+	re   *regexp.Regexp
+	repl string
+}
+
+// NewNormalizer returns the normalizer described by spec, which is empty for
+// none, LineNumbers, or a regular expression
+func NewNormalizer(spec string) (Normalizer, error) {
+	switch spec {
+	case "":
+		return Normalizer{}, nil
+	case LineNumbers:
+		return Normalizer{Spec: spec, re: lineNumberPattern, repl: ":"}, nil
+	}
+	re, err := regexp.Compile(spec)
+	if err != nil {
+		return Normalizer{}, fmt.Errorf("invalid normalize pattern '%s': %w", spec, err)
+	}
+	return Normalizer{Spec: spec, re: re}, nil
+}
+
+// Items splits output into one item per non-blank line, normalized, with
+// surrounding whitespace removed
+func Items(output string, n Normalizer) []string {
+	var items []string
+	for _, line := range strings.Split(output, "\n") {
+		item := strings.TrimSpace(line)
+		if n.re != nil {
+			item = strings.TrimSpace(n.re.ReplaceAllString(item, n.repl))
+		}
+		if item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
+
+// DiffItems returns the items in head but not base, in head order, and those
+// in base but not head, in base order. Repeated items are matched one for one,
+// so a second copy of an existing item counts as added.
+func DiffItems(base []string, head []string) (added []string, removed []string) {
+	remaining := make(map[string]int, len(base))
+	for _, item := range base {
+		remaining[item]++
+	}
+	for _, item := range head {
+		if remaining[item] > 0 {
+			remaining[item]--
+		} else {
+			added = append(added, item)
+		}
+	}
+	for _, item := range base {
+		if remaining[item] > 0 {
+			remaining[item]--
+			removed = append(removed, item)
+		}
+	}
+	return added, removed
+}
@@ -0,0 +1,61 @@
+package parser
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestItems(t *testing.T) {
+	tests := []struct {
+		name      string
+		normalize string
+		output    string
+		want      []string
+	}{
+		{name: "lines trimmed", output: "  a  \n\n b\r\n", want: []string{"a", "b"}},
+		{name: "empty", output: "\n \n", want: nil},
+		{name: "line numbers", normalize: LineNumbers, output: "main.go:12:5: unused\nmain.go:40: TODO\n", want: []string{"main.go: unused", "main.go: TODO"}},
+		{name: "pattern removed", normalize: `\s+\(\d+ms\)$`, output: "test a (12ms)\ntest b (3ms)\n", want: []string{"test a", "test b"}},
+		{name: "normalized to nothing", normalize: `.*`, output: "a\nb\n", want: nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			n, err := NewNormalizer(tt.normalize)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := Items(tt.output, n); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("got %q, want %q", got, tt.want)
+			}
+		})
+	}
+
+	if _, err := NewNormalizer("("); err == nil {
+		t.Error("expected an invalid pattern to be rejected")
+	}
+}
+
+func TestDiffItems(t *testing.T) {
+	tests := []struct {
+		name    string
+		base    []string
+		head    []string
+		added   []string
+		removed []string
+	}{
+		{name: "unchanged, reordered", base: []string{"a", "b"}, head: []string{"b", "a"}},
+		{name: "added in head order", base: []string{"a"}, head: []string{"c", "a", "b"}, added: []string{"c", "b"}},
+		{name: "removed in base order", base: []string{"c", "a", "b"}, head: []string{"a"}, removed: []string{"c", "b"}},
+		{name: "second copy is added", base: []string{"a"}, head: []string{"a", "a"}, added: []string{"a"}},
+		{name: "one copy removed", base: []string{"a", "b", "a"}, head: []string{"a"}, removed: []string{"a", "b"}},
+		{name: "empty base", head: []string{"a"}, added: []string{"a"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			added, removed := DiffItems(tt.base, tt.head)
+			if !reflect.DeepEqual(added, tt.added) || !reflect.DeepEqual(removed, tt.removed) {
+				t.Errorf("got added %q, removed %q; want %q, %q", added, removed, tt.added, tt.removed)
+			}
+		})
+	}
+}
@@ -0,0 +1,33 @@
+package parser
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Keys parses output made of "key<TAB>number" lines, such as a count per file,
+// into the value of each key and the keys in order of first appearance. Blank
+// lines are skipped and the values of a repeated key are added together.
+func Keys(output string) (values map[string]float64, order []string, err error) {
+	values = make(map[string]float64)
+	for i, line := range strings.Split(output, "\n") {
+		line = strings.TrimRight(line, "\r")
+		if strings.TrimSpace(line) == "" {
+			continue
+		}
+		tab := strings.LastIndexByte(line, '\t')
+		if tab < 0 {
+			return nil, nil, fmt.Errorf("line %d is not 'key<TAB>number': '%s'", i+1, line)
+		}
+		key := line[:tab]
+		value, err := ParseNumber(line[tab+1:])
+		if err != nil || strings.TrimSpace(key) == "" {
+			return nil, nil, fmt.Errorf("line %d is not 'key<TAB>number': '%s'", i+1, line)
+		}
+		if _, seen := values[key]; !seen {
+			order = append(order, key)
+		}
+		values[key] += value
+	}
+	return values, order, nil
+}
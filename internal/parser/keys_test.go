@@ -0,0 +1,57 @@
+package parser
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestKeys(t *testing.T) {
+	tests := []struct {
+		name    string
+		output  string
+		values  map[string]float64
+		order   []string
+		wantErr string
+	}{
+		{
+			name:   "per file counts",
+			output: "b.go\t2\na.go\t1\n",
+			values: map[string]float64{"b.go": 2, "a.go": 1},
+			order:  []string{"b.go", "a.go"},
+		},
+		{
+			name:   "repeated key summed, blank and CRLF lines",
+			output: "a.go\t1\r\n\n  \na.go\t2.5\r\n",
+			values: map[string]float64{"a.go": 3.5},
+			order:  []string{"a.go"},
+		},
+		{
+			name:   "key containing a tab",
+			output: "dir\tname\t4\n",
+			values: map[string]float64{"dir\tname": 4},
+			order:  []string{"dir\tname"},
+		},
+		{name: "empty", output: "", values: map[string]float64{}},
+		{name: "no tab", output: "a.go\t1\na.go 2\n", wantErr: "line 2 is not"},
+		{name: "not a number", output: "a.go\tmany\n", wantErr: "line 1 is not"},
+		{name: "empty key", output: " \t3\n", wantErr: "line 1 is not"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			values, order, err := Keys(tt.output)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("got error %v, want one containing %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(values, tt.values) || !reflect.DeepEqual(order, tt.order) {
+				t.Errorf("got %v in order %q, want %v in order %q", values, order, tt.values, tt.order)
+			}
+		})
+	}
+}
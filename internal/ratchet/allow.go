@@ -0,0 +1,71 @@
+package ratchet
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/tiernacity/ratchet/internal/git"
+)
+
+// AllowTrailer is the commit trailer that lets a named metric regress, written
+// as `Ratchet-Allow: todo-count "vendored libfoo"`
+const AllowTrailer = "Ratchet-Allow"
+
+// Override is a Ratchet-Allow trailer that turned a failed comparison into a
+// warning
+type Override struct {
+	Commit string // Commit carrying the trailer
+	Reason string // Why the regression is intended, empty if not given
+}
+
+// allowances holds the Ratchet-Allow trailers on the commits between the
+// merge-base of HEAD and each base ref and HEAD, read once per ref
+type allowances map[string][]git.Trailer
+
+// apply passes a failed comparison if a trailer on the branch names the
+// metric, recording which. Failing to read the trailers leaves it failed.
+func (a allowances) apply(res *Result) {
+	m := res.Metric
+	if res.Err != nil || res.Passed || m.ComparisonType == NoComparison {
+		return
+	}
+	trailers, ok := a[m.BaseRef]
+	if !ok {
+		from, err := git.MergeBase(m.BaseRef)
+		if err == nil {
+			trailers, err = git.Trailers(from, AllowTrailer)
+		}
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Warning: cannot check for %s trailers: %v\n", AllowTrailer, err)
+		}
+		a[m.BaseRef] = trailers
+	}
+
+	for _, t := range trailers {
+		if name, reason := parseAllow(t.Value); name == m.key() {
+			res.Override = &Override{Commit: t.Commit, Reason: reason}
+			res.Passed = true
+			return
+		}
+	}
+}
+
+// parseAllow splits a trailer value into the metric it names and the reason,
+// either of which may be in double quotes, as an unnamed metric's command
+// must be
+func parseAllow(value string) (name string, reason string) {
+	value = strings.TrimSpace(value)
+	if strings.HasPrefix(value, `"`) {
+		if end := strings.Index(value[1:], `"`); end >= 0 {
+			name, reason = value[1:end+1], value[end+2:]
+		}
+	} else {
+		name, reason, _ = strings.Cut(value, " ")
+	}
+	reason = strings.TrimSpace(reason)
+	if len(reason) >= 2 && strings.HasPrefix(reason, `"`) && strings.HasSuffix(reason, `"`) {
+		reason = reason[1 : len(reason)-1]
+	}
+	return name, reason
+}
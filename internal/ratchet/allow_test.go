@@ -0,0 +1,107 @@
+package ratchet
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+)
+
+func TestParseAllow(t *testing.T) {
+	tests := []struct {
+		value  string
+		name   string
+		reason string
+	}{
+		{value: "todo-count", name: "todo-count"},
+		{value: `todo-count "vendored libfoo"`, name: "todo-count", reason: "vendored libfoo"},
+		{value: "todo-count vendored libfoo", name: "todo-count", reason: "vendored libfoo"},
+		{value: `  todo-count    "padded"  `, name: "todo-count", reason: "padded"},
+		{value: `"go vet ./..."`, name: "go vet ./..."},
+		{value: `"go vet ./..." "new analyser"`, name: "go vet ./...", reason: "new analyser"},
+		{value: `"go vet ./..."new analyser`, name: "go vet ./...", reason: "new analyser"},
+		{value: `todo-count "unclosed`, name: "todo-count", reason: `"unclosed`},
+		{value: `"unclosed name`, name: "", reason: ""},
+		{value: "", name: ""},
+	}
+
+	for _, tt := range tests {
+		name, reason := parseAllow(tt.value)
+		if name != tt.name || reason != tt.reason {
+			t.Errorf("parseAllow(%q) = %q, %q; want %q, %q", tt.value, name, reason, tt.name, tt.reason)
+		}
+	}
+}
+
+func TestAllowancesApply(t *testing.T) {
+	a := allowances{"main": {
+		{Commit: "c2", Value: "Lint"},
+		{Commit: "c2", Value: `"go vet ./..." "new analyser"`},
+		{Commit: "c1", Value: `todo-count "vendored libfoo"`},
+		{Commit: "c0", Value: "todo-count older"},
+	}}
+	failed := func(m Metric) *Result {
+		m.BaseRef = "main"
+		m.ComparisonType = LessEqual
+		return &Result{Metric: m}
+	}
+
+	tests := []struct {
+		name     string
+		res      *Result
+		override *Override
+	}{
+		{name: "named metric", res: failed(Metric{Name: "todo-count", Command: "grep -c TODO"}), override: &Override{Commit: "c1", Reason: "vendored libfoo"}},
+		{name: "unnamed metric by command", res: failed(Metric{Command: "go vet ./..."}), override: &Override{Commit: "c2", Reason: "new analyser"}},
+		{name: "names match exactly", res: failed(Metric{Name: "lint"})},
+		{name: "unknown metric", res: failed(Metric{Name: "coverage"})},
+		{name: "passed", res: &Result{Metric: Metric{Name: "todo-count", BaseRef: "main", ComparisonType: LessEqual}, Passed: true}},
+		{name: "errored", res: &Result{Metric: Metric{Name: "todo-count", BaseRef: "main", ComparisonType: LessEqual}, Err: errors.New("failed")}},
+		{name: "not compared", res: &Result{Metric: Metric{Name: "todo-count", BaseRef: "main"}}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			wasPassed := tt.res.Passed
+			a.apply(tt.res)
+			switch {
+			case tt.override == nil && tt.res.Override != nil:
+				t.Errorf("got override %+v, want none", tt.res.Override)
+			case tt.override != nil && (tt.res.Override == nil || *tt.res.Override != *tt.override):
+				t.Errorf("got override %+v, want %+v", tt.res.Override, tt.override)
+			}
+			if want := wasPassed || tt.override != nil; tt.res.Passed != want {
+				t.Errorf("passed %v, want %v", tt.res.Passed, want)
+			}
+		})
+	}
+}
+
+func TestAllowTrailerOnBranch(t *testing.T) {
+	initRepo(t)
+	commitFile(t, "a", "1\n", "a")
+	commitFile(t, "b", "1\n", "b")
+	gitRun(t, "checkout", "-q", "-b", "feature")
+	commitFile(t, "a", "2\n", "Raise a\n\nRatchet-Allow: a \"intended\"")
+	commitFile(t, "b", "2\n", "Raise b")
+
+	metrics := []Metric{
+		{Name: "a", Command: "cat a", BaseRef: "main", ComparisonType: LessEqual},
+		{Name: "b", Command: "cat b", BaseRef: "main", ComparisonType: LessEqual},
+		{Name: "same", Command: "echo 1", BaseRef: "main", ComparisonType: LessEqual},
+	}
+	results, err := Run(Options{Metrics: metrics, Output: &bytes.Buffer{}})
+	if !errors.Is(err, ErrComparisonFailed) {
+		t.Fatalf("got error %v, want b's regression to fail the run", err)
+	}
+
+	allowed := gitRun(t, "rev-parse", "HEAD~1")
+	if res := results[0]; !res.Passed || res.Override == nil || *res.Override != (Override{Commit: allowed, Reason: "intended"}) {
+		t.Errorf("a: passed %v with override %+v, want it allowed by %s", res.Passed, res.Override, allowed)
+	}
+	if res := results[1]; res.Passed || res.Override != nil {
+		t.Errorf("b: passed %v with override %+v, want it to fail", res.Passed, res.Override)
+	}
+	if res := results[2]; !res.Passed || res.Override != nil {
+		t.Errorf("same: passed %v with override %+v, want a plain pass", res.Passed, res.Override)
+	}
+}
@@ -0,0 +1,93 @@
+package ratchet
+
+import (
+	"crypto/sha256"
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+// BaseFailure is what happens when a metric cannot be measured on the base,
+// such as when the PR that adds a metric also adds the script it runs
+type BaseFailure int
+
+const (
+	// BaseFailureFail fails the metric, as for any other error
+	BaseFailureFail BaseFailure = iota
+	// BaseFailureWarn passes the metric with a warning, without comparing
+	BaseFailureWarn
+	// BaseFailureDefault compares HEAD against the metric's BaseDefault
+	BaseFailureDefault
+)
+
+// String returns the policy as written in the config
+func (p BaseFailure) String() string {
+	switch p {
+	case BaseFailureWarn:
+		return "warn"
+	case BaseFailureDefault:
+		return "default"
+	default:
+		return "fail"
+	}
+}
+
+// ParseBaseFailure parses a policy as written in the config, empty meaning fail
+func ParseBaseFailure(s string) (BaseFailure, error) {
+	switch s {
+	case "", "fail":
+		return BaseFailureFail, nil
+	case "warn":
+		return BaseFailureWarn, nil
+	case "default":
+		return BaseFailureDefault, nil
+	default:
+		return BaseFailureFail, fmt.Errorf("invalid base failure policy '%s': expected fail, warn or default", s)
+	}
+}
+
+// baseCommands is what the base output depends on apart from the commit: the
+// commands, and the contents of any helpers copied into the base worktree
+func (m Metric) baseCommands() ([]string, error) {
+	commands := m.commands()
+	for _, path := range m.BaseHelpers {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read base helper %s: %w", path, err)
+		}
+		commands = append(commands, fmt.Sprintf("helper %s %x", filepath.ToSlash(path), sha256.Sum256(data)))
+	}
+	return commands, nil
+}
+
+// copyHelpers copies the given files, relative to the current directory, from
+// the working copy to the same place in the base worktree, replacing any that
+// are there, so that the base is measured with HEAD's scripts
+func copyHelpers(dir string, paths []string) error {
+	for _, path := range paths {
+		info, err := os.Stat(path)
+		if err != nil {
+			return fmt.Errorf("failed to read base helper %s: %w", path, err)
+		}
+		if !info.Mode().IsRegular() {
+			return fmt.Errorf("base helper %s is not a file", path)
+		}
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return fmt.Errorf("failed to read base helper %s: %w", path, err)
+		}
+
+		dest := filepath.Join(dir, path)
+		if err := os.MkdirAll(filepath.Dir(dest), 0o755); err != nil {
+			return fmt.Errorf("failed to copy base helper %s: %w", path, err)
+		}
+		// Remove first, since the base may have the file without write permission
+		if err := os.Remove(dest); err != nil && !os.IsNotExist(err) {
+			return fmt.Errorf("failed to copy base helper %s: %w", path, err)
+		}
+		if err := os.WriteFile(dest, data, info.Mode().Perm()); err != nil {
+			return fmt.Errorf("failed to copy base helper %s: %w", path, err)
+		}
+	}
+	return nil
+}
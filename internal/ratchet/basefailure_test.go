@@ -0,0 +1,40 @@
+package ratchet
+
+import (
+	"io"
+	"testing"
+)
+
+func TestBaseHelpersDoNotLeakIntoSharedWorktree(t *testing.T) {
+	initRepo(t)
+	// The script only exists in the working copy, not on main
+	writeFile(t, "s/m.sh", "echo 4\n")
+
+	withHelper := Metric{
+		Name:           "a",
+		Command:        "sh s/m.sh",
+		BaseRef:        "main",
+		ComparisonType: LessEqual,
+		BaseHelpers:    []string{"s/m.sh"},
+	}
+	without := Metric{
+		Name:           "b",
+		Command:        "sh s/m.sh",
+		BaseRef:        "main",
+		ComparisonType: LessEqual,
+	}
+
+	results, err := Run(Options{Metrics: []Metric{withHelper, without}, Output: io.Discard})
+	if err == nil {
+		t.Fatal("expected the metric without helpers to fail")
+	}
+	if len(results) != 2 {
+		t.Fatalf("got %d results, want 2", len(results))
+	}
+	if res := results[0]; res.Err != nil || !res.Passed || res.BaseValue != 4 {
+		t.Errorf("metric a: err %v, passed %t, base %g; want a pass with base 4", res.Err, res.Passed, res.BaseValue)
+	}
+	if res := results[1]; res.Err == nil {
+		t.Errorf("metric b: measured base %g with a helper it did not declare", res.BaseValue)
+	}
+}
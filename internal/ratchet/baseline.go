@@ -0,0 +1,192 @@
+package ratchet
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/tiernacity/ratchet/internal/baseline"
+	"github.com/tiernacity/ratchet/internal/config"
+	"github.com/tiernacity/ratchet/internal/git"
+)
+
+// BaselineOptions configures an update of the baseline file
+type BaselineOptions struct {
+	Metrics  []Metric  // Metrics to measure; their ComparisonType says which values are better
+	Path     string    // Baseline file to update
+	Force    bool      // Record every value, even if it is worse than the baseline
+	Progress io.Writer // Where progress lines are printed, nil for none
+}
+
+// BaselineUpdate describes what happened to one metric's baseline
+type BaselineUpdate struct {
+	Metric   Metric
+	Value    float64 // Value measured in the working copy
+	Previous float64 // Baseline before the update, if Recorded
+	Recorded bool    // Whether the file already held a baseline for the metric
+	Updated  bool    // Whether Value replaced the baseline
+	Err      error   // Set if the value could not be measured
+}
+
+// UpdateBaseline measures every compared metric in the working copy and
+// records its value in the baseline file if there is none yet or the value
+// improves on it. The file is only rewritten if a value changed; the error is
+// non-nil if the file could not be read or written, or any metric could not
+// be measured.
+func UpdateBaseline(opts BaselineOptions) ([]BaselineUpdate, error) {
+	f, _, err := baseline.Load(opts.Path)
+	if err != nil {
+		return nil, err
+	}
+
+	var updates []BaselineUpdate
+	failed, changed := 0, false
+	for _, m := range opts.Metrics {
+		if m.ComparisonType == NoComparison {
+			continue
+		}
+		u := BaselineUpdate{Metric: m}
+		u.Previous, u.Recorded = f.Metrics[m.key()]
+
+		s := side{name: SideHead, where: "working copy", line: newProgressLine(m.Name, "HEAD", m, opts.Progress)}
+		output, _, err := runPipeline(context.Background(), m, s)
+		if err == nil {
+			u.Value, err = m.extract(output, "working copy")
+		}
+		if err != nil {
+			u.Err = err
+			failed++
+			updates = append(updates, u)
+			continue
+		}
+
+		if !u.Recorded || opts.Force || improves(m.ComparisonType, u.Value, u.Previous) {
+			u.Updated = !u.Recorded || u.Value != u.Previous
+		}
+		if u.Updated {
+			f.Metrics[m.key()] = u.Value
+			changed = true
+		}
+		updates = append(updates, u)
+	}
+
+	if len(updates) == 0 {
+		return nil, fmt.Errorf("no metric has a comparison operator saying which values are better; give one or use --direction")
+	}
+	if changed {
+		if err := f.Save(opts.Path); err != nil {
+			return updates, err
+		}
+	}
+	if failed > 0 {
+		return updates, fmt.Errorf("could not measure %d of %d metrics", failed, len(updates))
+	}
+	return updates, nil
+}
+
+// loadBaseline reads the baseline file that base values are taken from, as
+// committed at TrustedRef if set, so that a branch cannot loosen its own
+// baseline by editing it
+func (opts Options) loadBaseline() (*baseline.File, error) {
+	if opts.TrustedRef == "" {
+		f, exists, err := baseline.Load(opts.Baseline)
+		if err == nil && !exists {
+			err = fmt.Errorf("baseline file %s not found; create it with ratchet update-baseline", opts.Baseline)
+		}
+		return f, err
+	}
+
+	data, found, err := git.ShowFile(opts.TrustedRef, opts.Baseline)
+	if err != nil {
+		return nil, &GitError{Err: err}
+	}
+	if !found {
+		return nil, fmt.Errorf("baseline file %s not found at %s; create it with ratchet update-baseline", opts.Baseline, opts.TrustedRef)
+	}
+	return baseline.Parse(opts.TrustedRef+":"+opts.Baseline, data)
+}
+
+// tightenedFile returns the file that tightening rewrites
+func (opts Options) tightenedFile() string {
+	if opts.Baseline != "" {
+		return opts.Baseline
+	}
+	return opts.Tighten.ConfigFile
+}
+
+// tighten records each HEAD value that is strictly better than the stored
+// baseline, so that gains cannot later be given back within a tolerance, then
+// commits the file if asked. In baseline mode the values are kept in the
+// baseline file; otherwise they are the thresholds set in the config file,
+// and metrics compared with a base ref are left alone.
+func tighten(results []Result, opts Options) error {
+	path := opts.tightenedFile()
+	what := "baseline"
+	var stored map[string]float64
+	var save func(values map[string]float64) error
+	key := Metric.key
+	if opts.Baseline != "" {
+		f, _, err := baseline.Load(path)
+		if err != nil {
+			return err
+		}
+		stored = f.Metrics
+		save = func(values map[string]float64) error {
+			for k, v := range values {
+				f.Metrics[k] = v
+			}
+			return f.Save(path)
+		}
+	} else {
+		var err error
+		if stored, err = config.Thresholds(path); err != nil {
+			return err
+		}
+		save = func(values map[string]float64) error {
+			return config.SetThresholds(path, values)
+		}
+		what = "threshold"
+		// Thresholds are keyed by name, with "" for the config's single metric
+		key = func(m Metric) string { return m.Name }
+	}
+
+	updates := make(map[string]float64)
+	var changes []string
+	for _, res := range results {
+		m := res.Metric
+		if m.ComparisonType == NoComparison || res.Err != nil || !res.Passed {
+			continue
+		}
+		if opts.Baseline == "" && m.Threshold == nil {
+			continue
+		}
+		// Compare with the file rather than the base value, so that a baseline
+		// changed since it was read is never loosened
+		previous, ok := stored[key(m)]
+		if !ok || !improves(m.ComparisonType, res.HeadValue, previous) {
+			continue
+		}
+		updates[key(m)] = res.HeadValue
+		changes = append(changes, fmt.Sprintf("%s: %g -> %g", m.key(), previous, res.HeadValue))
+	}
+	if len(changes) == 0 {
+		return nil
+	}
+
+	if err := save(updates); err != nil {
+		return err
+	}
+	for _, c := range changes {
+		fmt.Fprintf(os.Stderr, "Tightened %s %s\n", what, c)
+	}
+	if opts.Tighten.Commit {
+		message := "Tighten ratchet " + what + "\n\n" + strings.Join(changes, "\n")
+		if err := git.CommitFile(path, message); err != nil {
+			return err
+		}
+		fmt.Fprintf(os.Stderr, "Committed %s\n", path)
+	}
+	return nil
+}
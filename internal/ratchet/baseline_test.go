@@ -0,0 +1,126 @@
+package ratchet
+
+import (
+	"bytes"
+	"errors"
+	"os"
+	"strings"
+	"testing"
+)
+
+func TestTightenConfigThreshold(t *testing.T) {
+	initRepo(t)
+	writeFile(t, ".ratchet", "metric: echo 3\nthreshold: 5 # lower is better\nle: main\n")
+	gitRun(t, "add", ".ratchet")
+	gitRun(t, "commit", "-q", "-m", "config")
+
+	threshold := 5.0
+	m := Metric{Command: "echo 3", BaseRef: "main", ComparisonType: LessEqual, Threshold: &threshold}
+	var out bytes.Buffer
+	_, err := Run(Options{
+		Metrics: []Metric{m},
+		Output:  &out,
+		Tighten: TightenOptions{Enabled: true, Commit: true, ConfigFile: ".ratchet"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	data, err := os.ReadFile(".ratchet")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := "metric: echo 3\nthreshold: 3 # lower is better\nle: main\n"; string(data) != want {
+		t.Errorf("config after tightening:\n%s\nwant\n%s", data, want)
+	}
+	if subject := gitRun(t, "log", "-1", "--format=%s"); subject != "Tighten ratchet threshold" {
+		t.Errorf("last commit %q, want the tightening commit", subject)
+	}
+}
+
+func TestTightenRefusedBeforeMeasuring(t *testing.T) {
+	initRepo(t)
+	writeFile(t, ".ratchet", "metric: echo 3\nthreshold: 5\nle: main\n")
+	gitRun(t, "add", ".ratchet")
+	gitRun(t, "commit", "-q", "-m", "config")
+	writeFile(t, "README", "changed\n")
+
+	threshold := 5.0
+	m := Metric{Command: "echo 3", BaseRef: "main", ComparisonType: LessEqual, Threshold: &threshold}
+	var out bytes.Buffer
+	results, err := Run(Options{
+		Metrics: []Metric{m},
+		Output:  &out,
+		Tighten: TightenOptions{Enabled: true, ConfigFile: ".ratchet"},
+	})
+	if err == nil || !strings.Contains(err.Error(), "uncommitted changes to README") {
+		t.Fatalf("got error %v, want a refusal naming README", err)
+	}
+	if results != nil || out.Len() > 0 {
+		t.Errorf("got results %v and output %q; want nothing measured or reported", results, out.String())
+	}
+}
+
+func TestBaselineFromTrustedRef(t *testing.T) {
+	initRepo(t)
+	writeFile(t, ".ratchet-baseline.json", `{"version": 1, "metrics": {"echo 7": 5}}`+"\n")
+	gitRun(t, "add", ".ratchet-baseline.json")
+	gitRun(t, "commit", "-q", "-m", "baseline")
+
+	// The branch loosens its own baseline
+	writeFile(t, ".ratchet-baseline.json", `{"version": 1, "metrics": {"echo 7": 10}}`+"\n")
+
+	m := Metric{Command: "echo 7", BaseRef: ".ratchet-baseline.json", ComparisonType: LessEqual}
+	run := func(trusted string) ([]Result, error) {
+		return Run(Options{
+			Metrics:    []Metric{m},
+			Output:     &bytes.Buffer{},
+			Baseline:   ".ratchet-baseline.json",
+			TrustedRef: trusted,
+		})
+	}
+
+	if _, err := run(""); err != nil {
+		t.Fatalf("against the working copy's baseline: %v", err)
+	}
+	results, err := run("main")
+	if !errors.Is(err, ErrComparisonFailed) {
+		t.Fatalf("got error %v, want the comparison with main's baseline to fail", err)
+	}
+	if results[0].BaseValue != 5 {
+		t.Errorf("base value %g, want 5 from main", results[0].BaseValue)
+	}
+}
+
+func TestTightenRefusedWithUntrackedFile(t *testing.T) {
+	initRepo(t)
+	writeFile(t, ".ratchet-baseline.json", `{"version": 1, "metrics": {"ls *.go | wc -l": 5}}`+"\n")
+	gitRun(t, "add", ".ratchet-baseline.json")
+	gitRun(t, "commit", "-q", "-m", "baseline")
+
+	// A new file that was never committed changes the metric
+	writeFile(t, "new.go", "package new\n")
+	// Ignored files cannot reach a commit, so they do not count
+	writeFile(t, ".gitignore", "*.log\n")
+	gitRun(t, "add", ".gitignore")
+	gitRun(t, "commit", "-q", "-m", "ignore logs")
+	writeFile(t, "build.log", "\n")
+
+	m := Metric{Command: "ls *.go | wc -l", BaseRef: ".ratchet-baseline.json", ComparisonType: LessEqual}
+	_, err := Run(Options{
+		Metrics:  []Metric{m},
+		Output:   &bytes.Buffer{},
+		Baseline: ".ratchet-baseline.json",
+		Tighten:  TightenOptions{Enabled: true},
+	})
+	if err == nil || !strings.Contains(err.Error(), "uncommitted changes to new.go") || strings.Contains(err.Error(), "build.log") {
+		t.Fatalf("got error %v, want a refusal naming new.go alone", err)
+	}
+	data, err := os.ReadFile(".ratchet-baseline.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := `{"version": 1, "metrics": {"ls *.go | wc -l": 5}}` + "\n"; string(data) != want {
+		t.Errorf("baseline changed to\n%s", data)
+	}
+}
@@ -0,0 +1,235 @@
+package ratchet
+
+import (
+	"fmt"
+	"io"
+	"math/bits"
+	"os"
+
+	"github.com/tiernacity/ratchet/internal/cache"
+	"github.com/tiernacity/ratchet/internal/git"
+)
+
+// BisectOptions configures a search for the commit that regressed a metric
+type BisectOptions struct {
+	// Metric to measure. Its ComparisonType is the test a good commit passes
+	// against the threshold, e.g. LessEqual if lower values are better, and its
+	// Tolerance loosens that test as it would a comparison.
+	Metric Metric
+
+	Good      string       // Ref at which the metric is known to be good
+	Bad       string       // Ref at which the metric is known to be bad
+	Threshold *float64     // Value a good commit is tested against; the value at Good if nil
+	Cache     *cache.Cache // Cache of results by commit, nil to always measure
+	Notes     NotesOptions // Reuse and recording of values in git notes
+	Progress  io.Writer    // Where each tested commit is reported, nil for none
+}
+
+// BisectResult identifies the first commit at which the metric went bad
+type BisectResult struct {
+	First       git.Commit   // First commit failing the test
+	Parents     []git.Commit // Parents of First, first parent first
+	Before      []float64    // Value at each of Parents
+	After       float64      // Value at First
+	Threshold   float64      // Value the test was applied against
+	Limit       float64      // Worst passing value, after applying any tolerance
+	Tested      int          // Number of commits whose value was obtained
+	Description string       // Test a good commit passes, e.g. "less than or equal to"
+}
+
+// Bisect searches the commits between Good and Bad for the first one whose
+// metric fails the test. A binary search runs along the first-parent history
+// of Bad; if the first failing commit there is a merge whose other parent also
+// fails, the search continues along that parent's history, so that the
+// commit found is the one that brought the regression in and its parents are
+// the commits it was made on. Commits are measured in temporary worktrees, so
+// the working copy and any git bisect session in progress are left untouched.
+func Bisect(opts BisectOptions) (*BisectResult, error) {
+	if !git.IsGitRepository() {
+		return nil, fmt.Errorf("not a git repository")
+	}
+
+	goodCommit, err := git.LookupCommit(opts.Good)
+	if err != nil {
+		return nil, fmt.Errorf("good ref '%s' not found", opts.Good)
+	}
+	badCommit, err := git.LookupCommit(opts.Bad)
+	if err != nil {
+		return nil, fmt.Errorf("bad ref '%s' not found", opts.Bad)
+	}
+	if !git.IsAncestor(goodCommit.SHA, badCommit.SHA) {
+		return nil, fmt.Errorf("good ref '%s' is not an ancestor of bad ref '%s'", opts.Good, opts.Bad)
+	}
+	if goodCommit.SHA == badCommit.SHA {
+		return nil, fmt.Errorf("good ref '%s' and bad ref '%s' are the same commit", opts.Good, opts.Bad)
+	}
+
+	cleanups := newCleanupSet()
+	defer cleanups.runAll()
+
+	if opts.Notes.Remote != "" && (opts.Notes.Read || opts.Notes.Write) {
+		if err := git.FetchNotes(opts.Notes.Remote); err != nil {
+			fmt.Fprintf(os.Stderr, "Warning: %v\n", err)
+		}
+	}
+
+	m := &measurer{metrics: []Metric{opts.Metric}, cache: opts.Cache, notes: opts.Notes, cleanups: cleanups}
+	values := make(map[string]float64)
+	value := func(c git.Commit) (float64, bool, error) {
+		if v, ok := values[c.SHA]; ok {
+			return v, false, nil
+		}
+		hv := m.measure(c).Values[0]
+		if hv.Err != nil {
+			return 0, false, fmt.Errorf("could not measure commit %s: %w", shortSHA(c.SHA), hv.Err)
+		}
+		values[c.SHA] = hv.Value
+		return hv.Value, true, nil
+	}
+
+	ct := opts.Metric.ComparisonType
+	res := &BisectResult{Description: ct.description()}
+
+	// Establish the threshold and check that the ends really are good and bad
+	goodValue, _, err := value(goodCommit)
+	if err != nil {
+		return nil, err
+	}
+	res.Threshold = goodValue
+	if opts.Threshold != nil {
+		res.Threshold = *opts.Threshold
+	}
+	_, res.Limit, _ = check(ct, res.Threshold, res.Threshold, opts.Metric.Tolerance)
+	passes := func(v float64) bool {
+		passed, _, _ := check(ct, v, res.Threshold, opts.Metric.Tolerance)
+		return passed
+	}
+	opts.report(goodCommit, goodValue, passes(goodValue))
+	if !passes(goodValue) {
+		return nil, fmt.Errorf("good ref '%s' (%g) is not %s %g", opts.Good, goodValue, res.Description, res.Limit)
+	}
+
+	// test reports whether a commit passes, printing its value when first measured
+	test := func(c git.Commit) (bool, error) {
+		v, measured, err := value(c)
+		if err != nil {
+			return false, err
+		}
+		if measured {
+			opts.report(c, v, passes(v))
+		}
+		return passes(v), nil
+	}
+	if ok, err := test(badCommit); err != nil {
+		return nil, err
+	} else if ok {
+		return nil, fmt.Errorf("bad ref '%s' (%g) is %s %g, so there is nothing to find", opts.Bad, values[badCommit.SHA], res.Description, res.Limit)
+	}
+
+	first, err := opts.search(goodCommit, badCommit, test)
+	if err != nil {
+		return nil, err
+	}
+	res.First, res.After = first, values[first.SHA]
+	for _, sha := range first.Parents {
+		parent, err := git.LookupCommit(sha)
+		if err != nil {
+			return nil, err
+		}
+		// Every parent was tested on the way to first
+		if _, err := test(parent); err != nil {
+			return nil, err
+		}
+		res.Parents = append(res.Parents, parent)
+		res.Before = append(res.Before, values[sha])
+	}
+	res.Tested = len(values)
+	return res, nil
+}
+
+// search finds the first failing commit between good, which passes, and bad,
+// which fails. It bisects the first-parent history of bad, then checks the
+// parents of the commit found: one that fails other than along that history
+// means the regression came in through it, so the search continues between it
+// and a passing common ancestor with good.
+func (opts BisectOptions) search(good git.Commit, bad git.Commit, test func(git.Commit) (bool, error)) (git.Commit, error) {
+	path, err := git.FirstParentPath(good.SHA, bad.SHA)
+	if err != nil {
+		return git.Commit{}, err
+	}
+	if len(path) == 0 {
+		return git.Commit{}, fmt.Errorf("commit %s is not an ancestor of %s", shortSHA(good.SHA), shortSHA(bad.SHA))
+	}
+
+	// Invariant: path[hi] fails and path[lo] passes, with lo of -1 standing
+	// for the commits before the path, whose values are not known
+	lo, hi := -1, len(path)-1
+	for hi-lo > 1 {
+		if opts.Progress != nil {
+			left := hi - lo - 1
+			fmt.Fprintf(opts.Progress, "Bisecting: %d commits left to test (roughly %d steps)\n", left, bits.Len(uint(left)))
+		}
+		mid := lo + (hi-lo)/2
+		good, err := test(path[mid])
+		if err != nil {
+			return git.Commit{}, err
+		}
+		if good {
+			lo = mid
+		} else {
+			hi = mid
+		}
+	}
+	first := path[hi]
+
+	for i, sha := range first.Parents {
+		if i == 0 && lo >= 0 {
+			// path[lo], which passes
+			continue
+		}
+		parent, err := git.LookupCommit(sha)
+		if err != nil {
+			return git.Commit{}, err
+		}
+		ok, err := test(parent)
+		if err != nil {
+			return git.Commit{}, err
+		}
+		if ok {
+			continue
+		}
+
+		// Search below the failing parent from a commit known to pass
+		from := good
+		if !git.IsAncestor(good.SHA, parent.SHA) {
+			base, err := git.CommonAncestor(good.SHA, parent.SHA)
+			if err != nil {
+				continue
+			}
+			if from, err = git.LookupCommit(base); err != nil {
+				return git.Commit{}, err
+			}
+			if ok, err := test(from); err != nil {
+				return git.Commit{}, err
+			} else if !ok {
+				// Failing on both sides since they forked: the merge is
+				// where the failure reached this history
+				continue
+			}
+		}
+		return opts.search(from, parent, test)
+	}
+	return first, nil
+}
+
+// report prints the value found at a commit and whether it passed
+func (opts BisectOptions) report(commit git.Commit, value float64, good bool) {
+	if opts.Progress == nil {
+		return
+	}
+	verdict := "bad"
+	if good {
+		verdict = "good"
+	}
+	fmt.Fprintf(opts.Progress, "%s %g %s  %s\n", shortSHA(commit.SHA), value, verdict, commit.Subject)
+}
@@ -0,0 +1,77 @@
+package ratchet
+
+import "testing"
+
+// commitFile writes a file and commits it with message as the subject,
+// returning the new commit
+func commitFile(t *testing.T, path string, content string, message string) string {
+	t.Helper()
+	writeFile(t, path, content)
+	gitRun(t, "add", path)
+	gitRun(t, "commit", "-q", "-m", message)
+	return gitRun(t, "rev-parse", "HEAD")
+}
+
+func TestBisectFollowsMergedBranch(t *testing.T) {
+	initRepo(t)
+	base := gitRun(t, "rev-parse", "HEAD")
+
+	// The TODO comes in on a side branch, merged after two commits on main
+	gitRun(t, "checkout", "-q", "-b", "side")
+	b1 := commitFile(t, "t", "TODO\n", "b1")
+	commitFile(t, "g", "y\n", "b2")
+	gitRun(t, "checkout", "-q", "main")
+	commitFile(t, "a", "a1\n", "a1")
+	a2 := commitFile(t, "a", "a1\na2\n", "a2")
+	gitRun(t, "merge", "-q", "--no-edit", "-m", "M", "side")
+
+	for _, good := range []string{"main~3", a2} {
+		res, err := Bisect(BisectOptions{
+			Metric: Metric{Command: "cat * | grep -c TODO || true", ComparisonType: LessEqual},
+			Good:   good,
+			Bad:    "HEAD",
+		})
+		if err != nil {
+			t.Fatalf("good %s: %v", good, err)
+		}
+		if res.First.SHA != b1 {
+			t.Errorf("good %s: first bad commit %s %q, want b1", good, res.First.SHA, res.First.Subject)
+		}
+		if len(res.Parents) != 1 || res.Parents[0].SHA != base || res.Before[0] != 0 {
+			t.Errorf("good %s: before %v at %v, want 0 at b1's parent %s", good, res.Before, res.Parents, base)
+		}
+		if res.After != 1 {
+			t.Errorf("good %s: after %g, want 1", good, res.After)
+		}
+	}
+}
+
+func TestBisectFindsMerge(t *testing.T) {
+	initRepo(t)
+
+	// Neither branch fails on its own; only the merge has both files
+	gitRun(t, "checkout", "-q", "-b", "side")
+	p := commitFile(t, "p", "\n", "add p")
+	gitRun(t, "checkout", "-q", "main")
+	q := commitFile(t, "q", "\n", "add q")
+	gitRun(t, "merge", "-q", "--no-edit", "-m", "M", "side")
+	merge := gitRun(t, "rev-parse", "HEAD")
+
+	res, err := Bisect(BisectOptions{
+		Metric: Metric{Command: "ls p q 2>/dev/null | wc -l", ComparisonType: LessEqual},
+		Good:   "main~1",
+		Bad:    "HEAD",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.First.SHA != merge {
+		t.Errorf("first bad commit %q, want the merge", res.First.Subject)
+	}
+	if len(res.Parents) != 2 || res.Parents[0].SHA != q || res.Parents[1].SHA != p {
+		t.Fatalf("parents %v, want q then p", res.Parents)
+	}
+	if res.Before[0] != 1 || res.Before[1] != 1 || res.After != 2 {
+		t.Errorf("before %v, after %g; want 1 at each parent and 2 after", res.Before, res.After)
+	}
+}
@@ -0,0 +1,62 @@
+package ratchet
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/tiernacity/ratchet/internal/cache"
+)
+
+func TestCacheHitSkipsWorktree(t *testing.T) {
+	initRepo(t)
+	writeFile(t, "value", "3\n")
+	gitRun(t, "add", "value")
+	gitRun(t, "commit", "-q", "-m", "value")
+	base := gitRun(t, "rev-parse", "HEAD")
+
+	c := cache.New(filepath.Join(t.TempDir(), "cache"))
+	m := Metric{Command: "cat value", BaseRef: "main", ComparisonType: LessEqual}
+	run := func(m Metric) ([]Result, error) {
+		return Run(Options{Metrics: []Metric{m}, Cache: c, Output: &bytes.Buffer{}})
+	}
+
+	// The first run measures the base and stores its output
+	results, err := run(m)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if results[0].BaseOrigin != "measured" {
+		t.Errorf("first run's base came from %s, want measured", results[0].BaseOrigin)
+	}
+	commands, err := m.baseCommands()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if output, ok := c.Get(cache.Key(base, commands...)); !ok || output != "3" {
+		t.Fatalf("cache holds %q, %v; want the base output", output, ok)
+	}
+
+	// With nowhere to create a worktree, only a cache hit can succeed
+	blocked := filepath.Join(t.TempDir(), "file")
+	if err := os.WriteFile(blocked, nil, 0o644); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("TMPDIR", blocked)
+	t.Setenv("RUNNER_TEMP", "")
+
+	results, err = run(m)
+	if err != nil {
+		t.Fatalf("cached run: %v", err)
+	}
+	if results[0].BaseOrigin != "cached" || results[0].BaseValue != 3 {
+		t.Errorf("base %g from %s, want 3 from the cache", results[0].BaseValue, results[0].BaseOrigin)
+	}
+
+	// Another command misses the cache and has to create a worktree
+	m.Pre = "true"
+	if _, err := run(m); err == nil {
+		t.Error("expected a run with a different pre command to need a worktree")
+	}
+}
@@ -0,0 +1,183 @@
+package ratchet
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// Tolerance is an allowance by which the HEAD metric may be worse than the
+// base and still pass. The zero value is a strict comparison.
+type Tolerance struct {
+	Value    float64 // Absolute amount, or a percentage of the base if Relative
+	Relative bool    // Whether Value is a percentage of the base value
+}
+
+// ParseTolerance parses an absolute ("0.5") or relative ("1%") tolerance
+func ParseTolerance(s string) (Tolerance, error) {
+	s = strings.TrimSpace(s)
+	if s == "" {
+		return Tolerance{}, nil
+	}
+
+	t := Tolerance{}
+	number := s
+	if strings.HasSuffix(s, "%") {
+		t.Relative = true
+		number = strings.TrimSpace(strings.TrimSuffix(s, "%"))
+	}
+
+	value, err := strconv.ParseFloat(number, 64)
+	if err != nil || value < 0 || math.IsNaN(value) || math.IsInf(value, 0) {
+		return Tolerance{}, fmt.Errorf("invalid tolerance '%s': expected a non-negative number or percentage", s)
+	}
+	t.Value = value
+	return t, nil
+}
+
+// IsZero reports whether the tolerance allows no deviation at all
+func (t Tolerance) IsZero() bool {
+	return t.Value == 0
+}
+
+func (t Tolerance) String() string {
+	if t.Relative {
+		return fmt.Sprintf("%g%%", t.Value)
+	}
+	return fmt.Sprintf("%g", t.Value)
+}
+
+// amount returns the absolute allowance for the given base value
+func (t Tolerance) amount(base float64) float64 {
+	if t.Relative {
+		return math.Abs(base) * t.Value / 100
+	}
+	return t.Value
+}
+
+// description returns the comparison as used in status messages
+func (ct ComparisonType) description() string {
+	switch ct {
+	case LessThan:
+		return "less than"
+	case LessEqual:
+		return "less than or equal to"
+	case Equal:
+		return "equal to"
+	case GreaterEqual:
+		return "greater than or equal to"
+	case GreaterThan:
+		return "greater than"
+	default:
+		return ""
+	}
+}
+
+// check applies the comparison to the HEAD and base values, widening it by the
+// tolerance. It returns whether the test passed, the worst passing HEAD value
+// (the limit) and how far HEAD is from that limit, negative when failing. For
+// Equal the limit is the upper bound of the band around the base.
+func check(ct ComparisonType, current float64, base float64, tol Tolerance) (bool, float64, float64) {
+	allowance := tol.amount(base)
+
+	var passed bool
+	var limit, margin float64
+	switch ct {
+	case LessThan:
+		limit = base + allowance
+		margin = limit - current
+		passed = current < limit
+	case LessEqual:
+		limit = base + allowance
+		margin = limit - current
+		passed = current <= limit
+	case Equal:
+		limit = base + allowance
+		margin = allowance - math.Abs(current-base)
+		passed = margin >= 0
+	case GreaterEqual:
+		limit = base - allowance
+		margin = current - limit
+		passed = current >= limit
+	case GreaterThan:
+		limit = base - allowance
+		margin = current - limit
+		passed = current > limit
+	default:
+		return true, 0, 0
+	}
+
+	return passed, roundNoise(limit), roundNoise(margin)
+}
+
+// improves reports whether current is strictly better than base for the
+// comparison. Nothing improves on an Equal comparison.
+func improves(ct ComparisonType, current float64, base float64) bool {
+	switch ct {
+	case LessThan, LessEqual:
+		return current < base
+	case GreaterEqual, GreaterThan:
+		return current > base
+	default:
+		return false
+	}
+}
+
+// roundNoise rounds away the floating point noise introduced by arithmetic on
+// metric values, so that 81.19 - 80.7 is reported as 0.49
+func roundNoise(v float64) float64 {
+	rounded, err := strconv.ParseFloat(strconv.FormatFloat(v, 'g', 10, 64), 64)
+	if err != nil {
+		return v
+	}
+	return rounded
+}
+
+// Status describes the outcome of a comparison, including the tolerance that
+// was applied and how close HEAD came to the limit. In key mode it describes
+// the total, which may pass while some keys fail, and for SARIF the results
+// new in HEAD. A comparison allowed by a trailer is described as it failed.
+// A metric whose base could not be measured may not have been compared.
+func (res Result) Status() string {
+	m := res.Metric
+	if res.BaseErr != nil && m.BaseFailure == BaseFailureWarn {
+		return fmt.Sprintf("%s metric (%g) was not compared, since it could not be measured on %s", res.HeadRef, res.HeadValue, m.BaseRef)
+	}
+	if res.Findings != nil {
+		count := len(res.Findings.New)
+		noun := "findings"
+		if count == 1 {
+			noun = "finding"
+		}
+		if count == 0 {
+			return fmt.Sprintf("%s has no new findings compared with %s (%g before, %g now)", res.HeadRef, m.BaseRef, res.BaseValue, res.HeadValue)
+		}
+		return fmt.Sprintf("%s has %d new %s compared with %s (%g before, %g now)", res.HeadRef, count, noun, m.BaseRef, res.BaseValue, res.HeadValue)
+	}
+
+	passed := res.Passed
+	if len(res.Keys) > 0 || res.Override != nil {
+		passed, _, _ = check(m.ComparisonType, res.HeadValue, res.BaseValue, m.Tolerance)
+	}
+	verdict := "is"
+	if !passed {
+		verdict = "is NOT"
+	}
+	subject, base := "metric", m.BaseRef
+	if res.Coverage != nil {
+		subject = "changed-line coverage"
+	}
+	if res.BaseOrigin == "threshold" || res.BaseOrigin == "default" {
+		base = res.BaseOrigin
+	}
+	line := fmt.Sprintf("%s %s (%g) %s %s %s (%g)", res.HeadRef, subject, res.HeadValue, verdict, m.ComparisonType.description(), base, res.BaseValue)
+
+	if m.Tolerance.IsZero() {
+		return line
+	}
+	if m.ComparisonType == Equal {
+		return fmt.Sprintf("%s within tolerance %s (margin %g)", line, m.Tolerance, res.Margin)
+	}
+	return fmt.Sprintf("%s within tolerance %s (limit %g, margin %g)", line, m.Tolerance, res.Limit, res.Margin)
+}
@@ -0,0 +1,66 @@
+package ratchet
+
+import "testing"
+
+func TestParseTolerance(t *testing.T) {
+	tests := []struct {
+		in      string
+		want    Tolerance
+		wantErr bool
+	}{
+		{in: "", want: Tolerance{}},
+		{in: "0.5", want: Tolerance{Value: 0.5}},
+		{in: " 1 % ", want: Tolerance{Value: 1, Relative: true}},
+		{in: "-1", wantErr: true},
+		{in: "NaN", wantErr: true},
+		{in: "inf%", wantErr: true},
+		{in: "abc", wantErr: true},
+	}
+	for _, tt := range tests {
+		got, err := ParseTolerance(tt.in)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("ParseTolerance(%q) error %v, want error %v", tt.in, err, tt.wantErr)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("ParseTolerance(%q) = %+v, want %+v", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestCheck(t *testing.T) {
+	tests := []struct {
+		name    string
+		ct      ComparisonType
+		current float64
+		base    float64
+		tol     Tolerance
+		passed  bool
+		limit   float64
+		margin  float64
+	}{
+		{name: "lt strict equal fails", ct: LessThan, current: 10, base: 10, passed: false, limit: 10, margin: 0},
+		{name: "lt within tolerance", ct: LessThan, current: 10.4, base: 10, tol: Tolerance{Value: 0.5}, passed: true, limit: 10.5, margin: 0.1},
+		{name: "lt at the limit fails", ct: LessThan, current: 10.5, base: 10, tol: Tolerance{Value: 0.5}, passed: false, limit: 10.5, margin: 0},
+		{name: "le at the limit passes", ct: LessEqual, current: 10.5, base: 10, tol: Tolerance{Value: 0.5}, passed: true, limit: 10.5, margin: 0},
+		{name: "le beyond the limit", ct: LessEqual, current: 12, base: 10, tol: Tolerance{Value: 10, Relative: true}, passed: false, limit: 11, margin: -1},
+		{name: "le relative to a negative base", ct: LessEqual, current: -9, base: -10, tol: Tolerance{Value: 10, Relative: true}, passed: true, limit: -9, margin: 0},
+		{name: "eq below the band", ct: Equal, current: 9.4, base: 10, tol: Tolerance{Value: 0.5}, passed: false, limit: 10.5, margin: -0.1},
+		{name: "eq inside the band", ct: Equal, current: 9.6, base: 10, tol: Tolerance{Value: 0.5}, passed: true, limit: 10.5, margin: 0.1},
+		{name: "eq strict", ct: Equal, current: 10, base: 10, passed: true, limit: 10, margin: 0},
+		{name: "ge within relative tolerance", ct: GreaterEqual, current: 80.7, base: 81.19, tol: Tolerance{Value: 1, Relative: true}, passed: true, limit: 80.3781, margin: 0.3219},
+		{name: "ge noise is rounded", ct: GreaterEqual, current: 80.7, base: 81.19, passed: false, limit: 81.19, margin: -0.49},
+		{name: "gt at the limit fails", ct: GreaterThan, current: 9.5, base: 10, tol: Tolerance{Value: 0.5}, passed: false, limit: 9.5, margin: 0},
+		{name: "gt above", ct: GreaterThan, current: 11, base: 10, passed: true, limit: 10, margin: 1},
+		{name: "no comparison", ct: NoComparison, current: 100, base: 1, passed: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			passed, limit, margin := check(tt.ct, tt.current, tt.base, tt.tol)
+			if passed != tt.passed || limit != tt.limit || margin != tt.margin {
+				t.Errorf("check(%v, %g, %g, %s) = %v, %g, %g; want %v, %g, %g",
+					tt.ct, tt.current, tt.base, tt.tol, passed, limit, margin, tt.passed, tt.limit, tt.margin)
+			}
+		})
+	}
+}
@@ -0,0 +1,32 @@
+package ratchet
+
+import (
+	"github.com/tiernacity/ratchet/internal/coverage"
+	"github.com/tiernacity/ratchet/internal/git"
+)
+
+// changedLineCoverage measures how much of the working copy changed since the
+// merge-base of HEAD and the base ref is covered by HEAD's profile. With no
+// coverable changed lines there is nothing left uncovered, so it is 100%.
+func changedLineCoverage(m Metric, headOutput string) (*LineCoverage, float64, error) {
+	profile, err := coverage.Parse(headOutput)
+	if err != nil {
+		return nil, 0, &ParseError{Err: err}
+	}
+	forked, err := git.MergeBase(m.BaseRef)
+	if err != nil {
+		return nil, 0, &GitError{Err: err}
+	}
+	changed, err := git.ChangedLines(forked)
+	if err != nil {
+		return nil, 0, &GitError{Err: err}
+	}
+
+	lc := &LineCoverage{}
+	lc.Coverable, lc.Uncovered = profile.Changed(changed)
+	if lc.Coverable == 0 {
+		return lc, 100, nil
+	}
+	covered := lc.Coverable - len(lc.Uncovered)
+	return lc, roundNoise(100 * float64(covered) / float64(lc.Coverable)), nil
+}
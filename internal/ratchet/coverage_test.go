@@ -0,0 +1,52 @@
+package ratchet
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/tiernacity/ratchet/internal/git"
+)
+
+func TestChangedLinesNotRecordedAsHeadNote(t *testing.T) {
+	initRepo(t)
+	// Half the statements are covered overall
+	writeFile(t, "a.go", "package a\nvar x = 1\n")
+	writeFile(t, "cov.out", "mode: set\na.go:1.1,1.10 1 1\na.go:2.1,2.10 1 0\n")
+	gitRun(t, "add", ".")
+	gitRun(t, "commit", "-q", "-m", "coverage")
+	base := gitRun(t, "rev-parse", "HEAD")
+
+	// The branch changes only the covered line
+	gitRun(t, "checkout", "-q", "-b", "feature")
+	writeFile(t, "a.go", "package b\nvar x = 1\n")
+	gitRun(t, "commit", "-q", "-am", "rename")
+	head := gitRun(t, "rev-parse", "HEAD")
+
+	m := Metric{Command: "cat cov.out", BaseRef: "main", ComparisonType: GreaterEqual, Coverage: "-", ChangedLines: true}
+	results, err := Run(Options{
+		Metrics: []Metric{m},
+		Output:  &bytes.Buffer{},
+		Notes:   NotesOptions{Write: true},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if results[0].HeadValue != 100 || results[0].BaseValue != 50 {
+		t.Fatalf("head %g, base %g; want 100 on the changed lines against 50 overall", results[0].HeadValue, results[0].BaseValue)
+	}
+
+	notes, err := git.ReadNote(base)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if notes[m.key()] != 50 {
+		t.Errorf("base note %v, want the overall 50", notes)
+	}
+	notes, err = git.ReadNote(head)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if v, ok := notes[m.key()]; ok {
+		t.Errorf("HEAD note records %g, the changed-line coverage, as the metric's value", v)
+	}
+}
@@ -0,0 +1,83 @@
+package ratchet
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// ErrComparisonFailed is returned when a metric was measured but failed its
+// comparison: a genuine regression, as opposed to a failure to measure
+var ErrComparisonFailed = errors.New("metric test failed")
+
+// ReportedError wraps an error that has already been reported along with the
+// results, so that it is not printed again
+type ReportedError struct {
+	Err error
+}
+
+func (e *ReportedError) Error() string {
+	return e.Err.Error()
+}
+
+func (e *ReportedError) Unwrap() error {
+	return e.Err
+}
+
+// GitError reports a git operation that failed, such as resolving a base ref,
+// fetching it or creating a worktree for it
+type GitError struct {
+	Err error
+}
+
+func (e *GitError) Error() string {
+	return e.Err.Error()
+}
+
+func (e *GitError) Unwrap() error {
+	return e.Err
+}
+
+// StepError reports a pre, metric or post command that failed
+type StepError struct {
+	Step    string        // "pre", "metric" or "post"
+	Command string        // Command that failed
+	Side    string        // SideBase or SideHead
+	Where   string        // Ref or branch the command ran in
+	Timeout time.Duration // Set if the command was killed for running too long
+	Err     error         // Underlying execution error
+}
+
+func (e *StepError) Error() string {
+	if e.Timeout > 0 {
+		side := "base"
+		if e.Side == SideHead {
+			side = "HEAD"
+		}
+		return fmt.Sprintf("%s%s command '%s' timed out after %s in %s (%s)",
+			strings.ToUpper(e.Step[:1]), e.Step[1:], e.Command, e.Timeout, side, e.Where)
+	}
+	if e.Step == "metric" {
+		return fmt.Sprintf("Metric command '%s' failed in %s", e.Command, e.Where)
+	}
+	return fmt.Sprintf("Command '%s' failed in %s", e.Command, e.Where)
+}
+
+func (e *StepError) Unwrap() error {
+	return e.Err
+}
+
+// ParseError reports metric output that does not hold the value, such as
+// output that is not a number or a coverage profile that cannot be read
+type ParseError struct {
+	Err error
+}
+
+func (e *ParseError) Error() string {
+	return e.Err.Error()
+}
+
+func (e *ParseError) Unwrap() error {
+	return e.Err
+}
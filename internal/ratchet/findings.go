@@ -0,0 +1,33 @@
+package ratchet
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/tiernacity/ratchet/internal/sarif"
+)
+
+// compareFindings matches the results in the base and HEAD SARIF logs and
+// returns those new in HEAD, writing them as a SARIF log if the metric asks
+func compareFindings(m Metric, baseOutput string, headOutput string) (*FindingDiff, error) {
+	base, err := sarif.Parse(baseOutput)
+	if err != nil {
+		return nil, &ParseError{Err: fmt.Errorf("could not read SARIF from base: %v", err)}
+	}
+	head, err := sarif.Parse(headOutput)
+	if err != nil {
+		return nil, &ParseError{Err: fmt.Errorf("could not read SARIF from HEAD: %v", err)}
+	}
+	diff := &FindingDiff{New: sarif.New(base, head)}
+
+	if m.SARIFOutput != "" {
+		data, err := sarif.Filter(headOutput, diff.New)
+		if err != nil {
+			return nil, &ParseError{Err: fmt.Errorf("could not read SARIF from HEAD: %v", err)}
+		}
+		if err := os.WriteFile(m.SARIFOutput, data, 0o644); err != nil {
+			return nil, fmt.Errorf("failed to write SARIF output %s: %w", m.SARIFOutput, err)
+		}
+	}
+	return diff, nil
+}
@@ -0,0 +1,40 @@
+package ratchet
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestUnreadableOutputIsParseError(t *testing.T) {
+	const sarifLog = `{"version": "2.1.0", "runs": [{"results": []}]}`
+	tests := []struct {
+		name    string
+		compare func(base, head string) error
+		valid   string
+	}{
+		{"findings", func(base, head string) error {
+			_, err := compareFindings(Metric{SARIF: "-"}, base, head)
+			return err
+		}, sarifLog},
+		{"keys", func(base, head string) error {
+			_, err := compareKeys(Metric{ComparisonType: LessEqual}, base, head)
+			return err
+		}, "a\t1"},
+	}
+
+	for _, tt := range tests {
+		if err := tt.compare(tt.valid, tt.valid); err != nil {
+			t.Fatalf("%s: %v", tt.name, err)
+		}
+		for _, side := range []string{"base", "HEAD"} {
+			base, head := "not valid {", tt.valid
+			if side == "HEAD" {
+				base, head = head, base
+			}
+			var parseErr *ParseError
+			if err := tt.compare(base, head); !errors.As(err, &parseErr) {
+				t.Errorf("%s with unreadable %s output: got %v, want a parse error", tt.name, side, err)
+			}
+		}
+	}
+}
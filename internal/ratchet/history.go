@@ -0,0 +1,230 @@
+package ratchet
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"sync"
+
+	"github.com/tiernacity/ratchet/internal/cache"
+	"github.com/tiernacity/ratchet/internal/executor"
+	"github.com/tiernacity/ratchet/internal/git"
+)
+
+// HistoryOptions configures a walk over past commits
+type HistoryOptions struct {
+	Metrics     []Metric     // Metrics to measure at each commit
+	Range       string       // Revision range such as main~200..main, HEAD if empty
+	Since       string       // Only commits more recent than this date, if set
+	FirstParent bool         // Follow only the first parent of merges, leaving out merged branches' commits
+	Every       int          // Only every Nth commit counting back from the newest, if above 1
+	Jobs        int          // Commits measured at the same time, at least 1
+	Cache       *cache.Cache // Cache of results by commit, nil to always measure
+	Notes       NotesOptions // Reuse and recording of values in git notes
+	Progress    io.Writer    // Where a line is printed as each commit is measured, nil for none
+}
+
+// HistoryPoint holds the metric values measured at one commit
+type HistoryPoint struct {
+	Commit git.Commit
+	Values []HistoryValue // One per metric, in the order of HistoryOptions.Metrics
+}
+
+// HistoryValue is the value of one metric at one commit
+type HistoryValue struct {
+	Value  float64
+	Origin string // "measured", "cached" or "git note"
+	Err    error  // Set if the value could not be obtained
+}
+
+// History measures every metric at each selected commit, oldest first. Stored
+// results from notes and the cache are used where available, so only commits
+// lacking one are checked out into a temporary worktree.
+func History(opts HistoryOptions) ([]HistoryPoint, error) {
+	if !git.IsGitRepository() {
+		return nil, fmt.Errorf("not a git repository")
+	}
+
+	commits, err := git.ListCommits(opts.Range, opts.Since, opts.FirstParent)
+	if err != nil {
+		return nil, err
+	}
+	if len(commits) == 0 {
+		return nil, fmt.Errorf("no commits to measure")
+	}
+
+	// Sample from the newest commit back, so the tip is always included, then
+	// put the series in chronological order
+	var selected []git.Commit
+	for i := 0; i < len(commits); i += max(opts.Every, 1) {
+		selected = append(selected, commits[i])
+	}
+	for i, j := 0, len(selected)-1; i < j; i, j = i+1, j-1 {
+		selected[i], selected[j] = selected[j], selected[i]
+	}
+
+	cleanups := newCleanupSet()
+	defer cleanups.runAll()
+
+	if opts.Notes.Remote != "" && (opts.Notes.Read || opts.Notes.Write) {
+		if err := git.FetchNotes(opts.Notes.Remote); err != nil {
+			fmt.Fprintf(os.Stderr, "Warning: %v\n", err)
+		}
+	}
+
+	h := &measurer{metrics: opts.Metrics, cache: opts.Cache, notes: opts.Notes, cleanups: cleanups}
+	var progressMu sync.Mutex
+	done := 0
+	points := make([]HistoryPoint, len(selected))
+	indices := make(chan int)
+	var wg sync.WaitGroup
+	for w := 0; w < max(opts.Jobs, 1); w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range indices {
+				points[i] = h.measure(selected[i])
+				if opts.Progress != nil {
+					progressMu.Lock()
+					done++
+					fmt.Fprintf(opts.Progress, "[%d/%d] %s %s\n", done, len(selected),
+						shortSHA(points[i].Commit.SHA), formatValues(points[i].Values))
+					progressMu.Unlock()
+				}
+			}
+		}()
+	}
+	for i := range selected {
+		if cleanups.ctx.Err() != nil {
+			break
+		}
+		indices <- i
+	}
+	close(indices)
+	wg.Wait()
+	if cleanups.ctx.Err() != nil {
+		return nil, executor.ErrInterrupted
+	}
+
+	if opts.Notes.Write && opts.Notes.Remote != "" && h.noted {
+		if err := git.PushNotes(opts.Notes.Remote); err != nil {
+			fmt.Fprintf(os.Stderr, "Warning: %v\n", err)
+		}
+	}
+
+	return points, nil
+}
+
+// measurer obtains metric values at individual commits, reusing stored results
+// where possible. It is safe for concurrent use.
+type measurer struct {
+	metrics  []Metric
+	cache    *cache.Cache // Cache of results by commit, nil to always measure
+	notes    NotesOptions // Reuse and recording of values in git notes
+	cleanups *cleanupSet  // Where worktrees are registered for removal
+
+	gitMu sync.Mutex // Serialises worktree creation and note writes
+	noted bool       // Whether any note was written
+}
+
+// measure obtains the value of every metric at commit, checking it out only
+// if some value is not already stored
+func (h *measurer) measure(commit git.Commit) HistoryPoint {
+	where := shortSHA(commit.SHA)
+	point := HistoryPoint{Commit: commit, Values: make([]HistoryValue, len(h.metrics))}
+
+	var noted map[string]float64
+	if h.notes.Read {
+		values, err := git.ReadNote(commit.SHA)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Warning: %v\n", err)
+		}
+		noted = values
+	}
+
+	dir := ""
+	release := func() {}
+	defer func() { release() }()
+
+	for i, m := range h.metrics {
+		if value, ok := noted[m.key()]; ok {
+			point.Values[i] = HistoryValue{Value: value, Origin: "git note"}
+			continue
+		}
+
+		cacheKey := ""
+		if h.cache != nil {
+			cacheKey = cache.Key(commit.SHA, m.commands()...)
+			if output, ok := h.cache.Get(cacheKey); ok {
+				if value, err := m.extract(output, where); err == nil {
+					point.Values[i] = HistoryValue{Value: value, Origin: "cached"}
+					continue
+				}
+			}
+		}
+
+		if h.cleanups.ctx.Err() != nil {
+			point.Values[i].Err = executor.ErrInterrupted
+			continue
+		}
+		if dir == "" {
+			h.gitMu.Lock()
+			path, cleanup, err := git.CreateWorktree(commit.SHA)
+			h.gitMu.Unlock()
+			if err != nil {
+				point.Values[i].Err = fmt.Errorf("failed to create worktree for commit %s", where)
+				continue
+			}
+			dir = path
+			release = h.cleanups.add(cleanup)
+		}
+
+		s := side{name: SideBase, dir: dir, where: where, line: newProgressLine("", where, m, nil)}
+		output, _, err := runPipeline(h.cleanups.ctx, m, s)
+		if err != nil {
+			point.Values[i].Err = err
+			continue
+		}
+		value, err := m.extract(output, where)
+		if err != nil {
+			point.Values[i].Err = err
+			continue
+		}
+		point.Values[i] = HistoryValue{Value: value, Origin: "measured"}
+
+		// Remember the result for next time
+		if h.cache != nil {
+			if err := h.cache.Put(cacheKey, commit.SHA, m.commands(), output); err != nil {
+				fmt.Fprintf(os.Stderr, "Warning: %v\n", err)
+			}
+		}
+		if h.notes.Write {
+			h.gitMu.Lock()
+			if err := git.WriteNote(commit.SHA, m.key(), value); err != nil {
+				fmt.Fprintf(os.Stderr, "Warning: %v\n", err)
+			} else {
+				h.noted = true
+			}
+			h.gitMu.Unlock()
+		}
+	}
+
+	return point
+}
+
+// formatValues describes the values at one commit for progress output
+func formatValues(values []HistoryValue) string {
+	parts := make([]string, len(values))
+	for i, v := range values {
+		switch {
+		case v.Err != nil:
+			parts[i] = "error"
+		case v.Origin != "measured":
+			parts[i] = fmt.Sprintf("%g (%s)", v.Value, v.Origin)
+		default:
+			parts[i] = fmt.Sprintf("%g", v.Value)
+		}
+	}
+	return strings.Join(parts, " ")
+}
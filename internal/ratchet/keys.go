@@ -0,0 +1,70 @@
+package ratchet
+
+import (
+	"fmt"
+
+	"github.com/tiernacity/ratchet/internal/parser"
+)
+
+// KeyOptions configures key mode, where the metric outputs "key<TAB>number"
+// lines, such as a count per file, and the comparison is applied to each key
+// as well as to the total
+type KeyOptions struct {
+	Enabled     bool
+	AllowNew    bool // Whether keys absent from the base may have any value, rather than only zero
+	FailDeleted bool // Whether keys absent from HEAD fail the comparison
+}
+
+// KeyChange is a key that failed the comparison
+type KeyChange struct {
+	Key     string
+	Base    float64 // Value at the base, 0 for a new key
+	Head    float64 // Value at HEAD, 0 for a deleted key
+	New     bool    // Whether the key is absent from the base
+	Deleted bool    // Whether the key is absent from HEAD
+}
+
+// compareKeys applies the comparison to each key in the base and HEAD output
+// and returns the keys that fail it, in the order they appear. A strict
+// comparison is applied to each key in its non-strict form, since otherwise
+// every unchanged key would fail.
+func compareKeys(m Metric, baseOutput string, headOutput string) ([]KeyChange, error) {
+	base, baseOrder, err := parser.Keys(baseOutput)
+	if err != nil {
+		return nil, &ParseError{Err: fmt.Errorf("could not read keys in output from base: %v", err)}
+	}
+	head, headOrder, err := parser.Keys(headOutput)
+	if err != nil {
+		return nil, &ParseError{Err: fmt.Errorf("could not read keys in output from HEAD: %v", err)}
+	}
+
+	ct := m.ComparisonType
+	switch ct {
+	case LessThan:
+		ct = LessEqual
+	case GreaterThan:
+		ct = GreaterEqual
+	}
+
+	var failed []KeyChange
+	for _, key := range headOrder {
+		before, ok := base[key]
+		if !ok {
+			if !m.Keys.AllowNew && head[key] != 0 {
+				failed = append(failed, KeyChange{Key: key, Head: head[key], New: true})
+			}
+			continue
+		}
+		if passed, _, _ := check(ct, head[key], before, m.Tolerance); !passed {
+			failed = append(failed, KeyChange{Key: key, Base: before, Head: head[key]})
+		}
+	}
+	if m.Keys.FailDeleted {
+		for _, key := range baseOrder {
+			if _, ok := head[key]; !ok {
+				failed = append(failed, KeyChange{Key: key, Base: base[key], Deleted: true})
+			}
+		}
+	}
+	return failed, nil
+}
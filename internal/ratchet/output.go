@@ -0,0 +1,273 @@
+package ratchet
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"text/tabwriter"
+)
+
+// reportSingle prints the outcome of a lone metric in the original format
+func reportSingle(out io.Writer, res Result, verbose bool) error {
+	m := res.Metric
+	if res.Err != nil {
+		var stepErr *StepError
+		if errors.As(res.Err, &stepErr) {
+			// Includes any post command that also failed
+			fmt.Fprintln(os.Stderr, res.Err)
+			fmt.Fprintln(os.Stderr, "Failed")
+			return &ReportedError{Err: res.Err}
+		}
+		return res.Err
+	}
+
+	// If no comparison, just output the metric
+	if m.ComparisonType == NoComparison {
+		fmt.Fprintf(out, "%g\n", res.HeadValue)
+		return nil
+	}
+
+	// An allowed regression is always shown, so that it can be audited
+	if res.Override != nil {
+		if verbose {
+			fmt.Fprintln(out)
+		}
+		fmt.Fprintln(os.Stderr, res.Status())
+		reportOverride(os.Stderr, res.Override, "")
+		reportItems(os.Stderr, res.Items, "")
+		reportKeys(os.Stderr, res.Keys, "")
+		reportFindings(os.Stderr, res.Findings, "")
+		reportCoverage(os.Stderr, res.Coverage, "")
+		fmt.Fprintln(out, "Succeeded")
+		return nil
+	}
+
+	if res.Passed {
+		// Only show detailed status line if verbose (for passing tests)
+		if verbose {
+			// Add blank line before result
+			fmt.Fprintln(out)
+			fmt.Fprintln(out, res.Status())
+			reportItems(out, res.Items, "")
+			reportFindings(out, res.Findings, "")
+			reportCoverage(out, res.Coverage, "")
+		}
+		fmt.Fprintln(out, "Succeeded")
+		return nil
+	}
+
+	// Test failed - always show status line for failures
+	// Add blank line before result if verbose (since progress lines were shown)
+	if verbose {
+		fmt.Fprintln(out)
+	}
+	fmt.Fprintln(os.Stderr, res.Status())
+	reportItems(os.Stderr, res.Items, "")
+	reportKeys(os.Stderr, res.Keys, "")
+	reportFindings(os.Stderr, res.Findings, "")
+	reportCoverage(os.Stderr, res.Coverage, "")
+	fmt.Fprintln(os.Stderr, "Failed")
+	return &ReportedError{Err: ErrComparisonFailed}
+}
+
+// reportTable prints a pass/fail table covering several metrics
+func reportTable(out io.Writer, results []Result, verbose bool) error {
+	// Add blank line before the table if verbose (since progress lines were shown)
+	if verbose {
+		fmt.Fprintln(out)
+	}
+
+	failed, regressed := 0, 0
+	var firstErr error
+	tw := tabwriter.NewWriter(out, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "METRIC\tBASE\tBASE VALUE\tHEAD VALUE\tTEST\tRESULT")
+	for _, res := range results {
+		m := res.Metric
+		base, baseValue, headValue := "-", "-", "-"
+		if m.ComparisonType != NoComparison {
+			base = m.BaseRef
+		}
+
+		var status string
+		switch {
+		case res.Err != nil:
+			status = "error"
+			failed++
+			if firstErr == nil {
+				firstErr = res.Err
+			}
+		case m.ComparisonType == NoComparison:
+			status = "reported"
+			headValue = fmt.Sprintf("%g", res.HeadValue)
+		default:
+			baseValue = fmt.Sprintf("%g", res.BaseValue)
+			headValue = fmt.Sprintf("%g", res.HeadValue)
+			status = "passed"
+			if res.Override != nil {
+				status = "allowed"
+			} else if res.BaseErr != nil && m.BaseFailure == BaseFailureWarn {
+				baseValue = "-"
+				status = "not compared"
+			} else if !res.Passed {
+				status = "FAILED"
+				failed++
+				regressed++
+			}
+		}
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%s\t%s\n", m.Name, base, baseValue, headValue, m.ComparisonType.Symbol(), status)
+	}
+	if err := tw.Flush(); err != nil {
+		return fmt.Errorf("failed to write results: %w", err)
+	}
+
+	// An allowed regression is always shown, so that it can be audited
+	allowed := false
+	for _, res := range results {
+		if res.Override == nil {
+			continue
+		}
+		if !allowed {
+			fmt.Fprintln(os.Stderr)
+			allowed = true
+		}
+		fmt.Fprintf(os.Stderr, "%s: %s\n", res.Metric.Name, res.Status())
+		reportOverride(os.Stderr, res.Override, "  ")
+		reportItems(os.Stderr, res.Items, "  ")
+		reportKeys(os.Stderr, res.Keys, "  ")
+		reportFindings(os.Stderr, res.Findings, "  ")
+		reportCoverage(os.Stderr, res.Coverage, "  ")
+	}
+
+	if failed == 0 {
+		fmt.Fprintln(out, "Succeeded")
+		return nil
+	}
+
+	// Always show status lines for failed comparisons
+	if regressed > 0 {
+		fmt.Fprintln(os.Stderr)
+	}
+	for _, res := range results {
+		if res.Err == nil && !res.Passed {
+			fmt.Fprintf(os.Stderr, "%s: %s\n", res.Metric.Name, res.Status())
+			reportItems(os.Stderr, res.Items, "  ")
+			reportKeys(os.Stderr, res.Keys, "  ")
+			reportFindings(os.Stderr, res.Findings, "  ")
+			reportCoverage(os.Stderr, res.Coverage, "  ")
+		}
+	}
+	fmt.Fprintln(os.Stderr, "Failed")
+
+	// A metric that could not be measured explains the failure better than a
+	// regression, since measuring it again may succeed
+	if firstErr != nil {
+		return &ReportedError{Err: firstErr}
+	}
+	return &ReportedError{Err: ErrComparisonFailed}
+}
+
+// reportOverride says which commit allowed a failed comparison, and why
+func reportOverride(out io.Writer, o *Override, indent string) {
+	if o == nil {
+		return
+	}
+	reason := o.Reason
+	if reason == "" {
+		reason = "no reason given"
+	}
+	fmt.Fprintf(out, "%sAllowed by %s trailer on %s: %s\n", indent, AllowTrailer, shortSHA(o.Commit), reason)
+}
+
+// maxListedItems is the most added or removed items listed for one metric
+const maxListedItems = 50
+
+// reportItems lists the items added and removed since the base, if any
+func reportItems(out io.Writer, diff *ItemDiff, indent string) {
+	if diff == nil {
+		return
+	}
+	list := func(title string, mark string, items []string) {
+		if len(items) == 0 {
+			return
+		}
+		fmt.Fprintf(out, "%s%s (%d):\n", indent, title, len(items))
+		for i, item := range items {
+			if i == maxListedItems {
+				fmt.Fprintf(out, "%s  ... and %d more\n", indent, len(items)-i)
+				break
+			}
+			fmt.Fprintf(out, "%s  %s %s\n", indent, mark, item)
+		}
+	}
+	list("Added", "+", diff.Added)
+	list("Removed", "-", diff.Removed)
+}
+
+// reportKeys lists the keys that failed the comparison, with their values
+func reportKeys(out io.Writer, keys []KeyChange, indent string) {
+	if len(keys) == 0 {
+		return
+	}
+	fmt.Fprintf(out, "%sFailing keys (%d):\n", indent, len(keys))
+	for _, k := range keys {
+		switch {
+		case k.New:
+			fmt.Fprintf(out, "%s  %s: new with %g\n", indent, k.Key, k.Head)
+		case k.Deleted:
+			fmt.Fprintf(out, "%s  %s: deleted, was %g\n", indent, k.Key, k.Base)
+		default:
+			fmt.Fprintf(out, "%s  %s: %g -> %g\n", indent, k.Key, k.Base, k.Head)
+		}
+	}
+}
+
+// reportFindings lists the SARIF results new in HEAD, if any
+func reportFindings(out io.Writer, diff *FindingDiff, indent string) {
+	if diff == nil || len(diff.New) == 0 {
+		return
+	}
+	fmt.Fprintf(out, "%sNew findings (%d):\n", indent, len(diff.New))
+	for i, f := range diff.New {
+		if i == maxListedItems {
+			fmt.Fprintf(out, "%s  ... and %d more\n", indent, len(diff.New)-i)
+			break
+		}
+		fmt.Fprintf(out, "%s  + %s\n", indent, f)
+	}
+}
+
+// reportCoverage lists the changed lines that were not run, as ranges per file
+func reportCoverage(out io.Writer, lc *LineCoverage, indent string) {
+	if lc == nil || len(lc.Uncovered) == 0 {
+		return
+	}
+	fmt.Fprintf(out, "%sUncovered changed lines (%d of %d):\n", indent, len(lc.Uncovered), lc.Coverable)
+
+	var files []string
+	ranges := make(map[string][]string)
+	for i := 0; i < len(lc.Uncovered); {
+		first := lc.Uncovered[i]
+		j := i + 1
+		for j < len(lc.Uncovered) && lc.Uncovered[j].File == first.File && lc.Uncovered[j].Line == lc.Uncovered[j-1].Line+1 {
+			j++
+		}
+		if _, seen := ranges[first.File]; !seen {
+			files = append(files, first.File)
+		}
+		if last := lc.Uncovered[j-1].Line; last > first.Line {
+			ranges[first.File] = append(ranges[first.File], fmt.Sprintf("%d-%d", first.Line, last))
+		} else {
+			ranges[first.File] = append(ranges[first.File], fmt.Sprintf("%d", first.Line))
+		}
+		i = j
+	}
+	for i, file := range files {
+		if i == maxListedItems {
+			fmt.Fprintf(out, "%s  ... and %d more files\n", indent, len(files)-i)
+			break
+		}
+		fmt.Fprintf(out, "%s  %s: %s\n", indent, file, strings.Join(ranges[file], ", "))
+	}
+}
@@ -0,0 +1,110 @@
+package ratchet
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParallelRunsBothSidesAtOnce(t *testing.T) {
+	initRepo(t)
+	// Each side checks in, then waits up to five seconds for the other; run
+	// one after the other, the first side would see only itself
+	rendezvous := t.TempDir()
+	command := fmt.Sprintf(`touch %s/$$; for i in $(seq 50); do [ $(ls %[1]s | wc -l) -ge 2 ] && break; sleep 0.1; done; ls %[1]s | wc -l`, filepath.ToSlash(rendezvous))
+
+	var out bytes.Buffer
+	results, err := Run(Options{
+		Metrics:  []Metric{{Command: command, BaseRef: "main", ComparisonType: LessEqual}},
+		Parallel: true,
+		Verbose:  true,
+		Output:   &out,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if results[0].BaseValue != 2 || results[0].HeadValue != 2 {
+		t.Errorf("base saw %g and HEAD saw %g sides running, want 2 each", results[0].BaseValue, results[0].HeadValue)
+	}
+
+	// The board is redrawn in place, ending with both sides complete
+	if !strings.Contains(out.String(), "\033[2A") {
+		t.Errorf("progress was not redrawn as a board:\n%q", out.String())
+	}
+	lines := strings.Split(out.String(), "\n")
+	var last []string
+	for _, line := range lines {
+		if strings.Contains(line, "metric [") {
+			last = append(last, line)
+		}
+	}
+	if len(last) < 2 || !strings.Contains(last[len(last)-2], "main:") || !strings.Contains(last[len(last)-1], "HEAD:") ||
+		!strings.Contains(last[len(last)-2], "metric [x]") || !strings.Contains(last[len(last)-1], "metric [x]") {
+		t.Errorf("final board %q, want main and HEAD complete", last)
+	}
+}
+
+func TestParallelFailureCancelsOtherSide(t *testing.T) {
+	initRepo(t)
+	// The base has no value file and fails at once; HEAD would take a minute
+	writeFile(t, "slow", "")
+	writeFile(t, "value", "1\n")
+	m := Metric{Command: "if [ -f slow ]; then sleep 60; fi; cat value", BaseRef: "main", ComparisonType: LessEqual}
+
+	start := time.Now()
+	results, err := Run(Options{Metrics: []Metric{m}, Parallel: true, Output: &bytes.Buffer{}})
+	if elapsed := time.Since(start); elapsed > 20*time.Second {
+		t.Errorf("run took %s; HEAD was not cancelled", elapsed)
+	}
+	if err == nil {
+		t.Fatal("expected the run to fail")
+	}
+
+	var stepErr *StepError
+	if !errors.As(results[0].Err, &stepErr) || stepErr.Side != SideBase {
+		t.Fatalf("got error %v, want the base's step error", results[0].Err)
+	}
+	var headStopped bool
+	for _, step := range results[0].Steps {
+		if step.Side == SideHead && step.Step == "metric" && step.Err != nil {
+			headStopped = true
+		}
+	}
+	if !headStopped {
+		t.Errorf("steps %+v, want HEAD's metric stopped with an error", results[0].Steps)
+	}
+}
+
+func TestParallelMatchesSequential(t *testing.T) {
+	initRepo(t)
+	writeFile(t, "value", "3\n")
+	gitRun(t, "add", "value")
+	gitRun(t, "commit", "-q", "-m", "value")
+	writeFile(t, "value", "2\n")
+
+	m := Metric{Pre: "true", Command: "cat value", Post: "true", BaseRef: "main", ComparisonType: LessThan}
+	summary := func(parallel bool) []string {
+		results, err := Run(Options{Metrics: []Metric{m, {Command: "echo 5", BaseRef: "main", ComparisonType: GreaterThan}}, Parallel: parallel, Output: &bytes.Buffer{}})
+		if !errors.Is(err, ErrComparisonFailed) {
+			t.Fatalf("parallel %v: got error %v, want the second metric to fail", parallel, err)
+		}
+		var s []string
+		for _, res := range results {
+			s = append(s, fmt.Sprintf("%s base %g head %g passed %v", res.Metric.Command, res.BaseValue, res.HeadValue, res.Passed))
+			for _, step := range res.Steps {
+				s = append(s, fmt.Sprintf("  %s %s %q %v", step.Side, step.Step, step.Stdout, step.Err))
+			}
+		}
+		return s
+	}
+
+	sequential, parallel := summary(false), summary(true)
+	if !reflect.DeepEqual(sequential, parallel) {
+		t.Errorf("parallel results\n%s\ndiffer from sequential\n%s", strings.Join(parallel, "\n"), strings.Join(sequential, "\n"))
+	}
+}
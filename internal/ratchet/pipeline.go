@@ -0,0 +1,243 @@
+package ratchet
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/tiernacity/ratchet/internal/executor"
+)
+
+// Sides of a comparison, as recorded in step results
+const (
+	SideBase = "base"
+	SideHead = "head"
+)
+
+// StepResult records the execution of one pre, metric or post command
+type StepResult struct {
+	Side     string        // SideBase or SideHead
+	Step     string        // "pre", "metric" or "post"
+	Command  string        // Command that was run
+	Duration time.Duration // Wall-clock time the command took
+	Stdout   string        // What the command wrote to stdout
+	Stderr   string        // What the command wrote to stderr
+	Err      error         // Set if the command failed
+}
+
+// Timeouts limits how long each step may run. A zero duration means no limit.
+type Timeouts struct {
+	Pre    time.Duration
+	Metric time.Duration
+	Post   time.Duration
+}
+
+// ParseTimeouts parses a default timeout and per-step overrides, each written
+// as a Go duration such as "30s" or "10m". Empty strings are ignored.
+func ParseTimeouts(timeout, pre, metric, post string) (Timeouts, error) {
+	def, err := parseTimeout(timeout)
+	if err != nil {
+		return Timeouts{}, err
+	}
+	t := Timeouts{Pre: def, Metric: def, Post: def}
+	for _, step := range []struct {
+		value string
+		dest  *time.Duration
+	}{{pre, &t.Pre}, {metric, &t.Metric}, {post, &t.Post}} {
+		if step.value == "" {
+			continue
+		}
+		if *step.dest, err = parseTimeout(step.value); err != nil {
+			return Timeouts{}, err
+		}
+	}
+	return t, nil
+}
+
+// parseTimeout parses a single timeout, treating an empty string as no limit
+func parseTimeout(s string) (time.Duration, error) {
+	if s == "" {
+		return 0, nil
+	}
+	d, err := time.ParseDuration(strings.TrimSpace(s))
+	if err != nil || d < 0 {
+		return 0, fmt.Errorf("invalid timeout '%s': expected a duration such as 30s or 10m", s)
+	}
+	return d, nil
+}
+
+// forStep returns the timeout for a "pre", "metric" or "post" step
+func (t Timeouts) forStep(step string) time.Duration {
+	switch step {
+	case "pre":
+		return t.Pre
+	case "post":
+		return t.Post
+	default:
+		return t.Metric
+	}
+}
+
+// side describes where one run of the pipeline happens
+type side struct {
+	name  string        // SideBase or SideHead
+	dir   string        // Working directory, empty for the working copy
+	where string        // Ref or branch named in error messages
+	line  *progressLine // Progress line ticked off as steps complete
+}
+
+// runParallel runs the base and HEAD pipelines at the same time. The first
+// side to fail cancels the other, and its error is the one returned, unless
+// the metric tolerates base failures; a base failure is then returned as
+// baseErr and leaves HEAD to finish.
+func runParallel(parent context.Context, m Metric, base side, head side) (baseOutput string, currentOutput string, steps []StepResult, baseErr error, err error) {
+	if base.line.enabled {
+		board := &progressBoard{lines: []*progressLine{base.line, head.line}}
+		base.line.board = board
+		head.line.board = board
+		board.draw()
+	}
+
+	ctx, cancel := context.WithCancel(parent)
+	defer cancel()
+
+	var once sync.Once
+	var firstErr error
+	fail := func(err error) {
+		once.Do(func() {
+			firstErr = err
+			cancel()
+		})
+	}
+
+	var wg sync.WaitGroup
+	var baseSteps, headSteps []StepResult
+	wg.Add(2)
+	go func() {
+		defer wg.Done()
+		output, steps, err := runPipeline(ctx, m, base)
+		baseSteps = steps
+		if err != nil && m.BaseFailure != BaseFailureFail {
+			baseErr = err
+			return
+		}
+		if err != nil {
+			fail(err)
+			return
+		}
+		baseOutput = output
+	}()
+	go func() {
+		defer wg.Done()
+		output, steps, err := runPipeline(ctx, m, head)
+		headSteps = steps
+		if err != nil {
+			fail(err)
+			return
+		}
+		currentOutput = output
+	}()
+	wg.Wait()
+
+	return baseOutput, currentOutput, append(baseSteps, headSteps...), baseErr, firstErr
+}
+
+// runPipeline runs the pre, metric and post commands on one side, ticking off
+// each step on the progress line as it completes. It returns the metric output
+// along with a record of every step that was run. Post is a teardown: once
+// anything has started it runs even if pre or the metric failed, timed out or
+// was interrupted, and its failure is reported alongside theirs.
+func runPipeline(ctx context.Context, m Metric, s side) (string, []StepResult, error) {
+	var steps []StepResult
+	run := func(ctx context.Context, step string, command string) (string, error) {
+		stepCtx := ctx
+		timeout := m.Timeouts.forStep(step)
+		if timeout > 0 {
+			var cancel context.CancelFunc
+			stepCtx, cancel = context.WithTimeout(ctx, timeout)
+			defer cancel()
+		}
+
+		start := time.Now()
+		var output executor.Output
+		var err error
+		if step == "metric" && m.Count != nil {
+			// Built-in counts run in process rather than in a shell
+			output.Stdout, err = m.Count.Run(stepCtx, s.dir, m.countFormat())
+		} else {
+			output, err = executor.ExecuteContext(stepCtx, command, s.dir)
+		}
+		steps = append(steps, StepResult{
+			Side:     s.name,
+			Step:     step,
+			Command:  command,
+			Duration: time.Since(start),
+			Stdout:   output.Stdout,
+			Stderr:   output.Stderr,
+			Err:      err,
+		})
+		if err != nil {
+			stepErr := &StepError{Step: step, Command: command, Side: s.name, Where: s.where, Err: err}
+			if errors.Is(err, context.DeadlineExceeded) {
+				stepErr.Timeout = timeout
+			}
+			return "", stepErr
+		}
+		s.line.complete(step)
+		return output.Stdout, nil
+	}
+
+	// Nothing is started once ratchet has been interrupted, so there is
+	// nothing to tear down either
+	if ctx.Err() != nil {
+		return "", nil, executor.ErrInterrupted
+	}
+
+	s.line.start()
+	defer s.line.finish()
+
+	output, err := func() (string, error) {
+		// Run pre command if specified
+		if m.Pre != "" {
+			if _, err := run(ctx, "pre", m.Pre); err != nil {
+				return "", err
+			}
+		}
+
+		// Execute metric command
+		output, err := run(ctx, "metric", m.Command)
+		if err != nil {
+			return "", err
+		}
+
+		// A SARIF log or coverage profile written to a file stands in for
+		// stdout, read before post commands can clean it up
+		if path := m.outputFile(); path != "" && path != "-" {
+			data, err := os.ReadFile(filepath.Join(s.dir, path))
+			if errors.Is(err, os.ErrNotExist) {
+				return "", &ParseError{Err: fmt.Errorf("metric command did not write %s in %s", path, s.where)}
+			} else if err != nil {
+				return "", &ParseError{Err: fmt.Errorf("could not read %s in %s: %w", path, s.where, err)}
+			}
+			output = string(data)
+		}
+		return output, nil
+	}()
+
+	// Run post command if specified, even after an interrupt, which would
+	// otherwise stop it before it starts
+	if m.Post != "" {
+		if _, postErr := run(context.WithoutCancel(ctx), "post", m.Post); postErr != nil {
+			err = errors.Join(err, postErr)
+		}
+	}
+	if err != nil {
+		return "", steps, err
+	}
+	return output, steps, nil
+}
@@ -0,0 +1,174 @@
+package ratchet
+
+import (
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+)
+
+// buildProgressLine creates a progress line with checkboxes, padding the label
+// to width so that the lines for each side line up
+func buildProgressLine(label string, width int, preCmd string, hasMetric bool, postCmd string, preComplete bool, metricComplete bool, postComplete bool) string {
+	var parts []string
+
+	if preCmd != "" {
+		if preComplete {
+			parts = append(parts, "pre [x]")
+		} else {
+			parts = append(parts, "pre [ ]")
+		}
+	}
+
+	if hasMetric {
+		if metricComplete {
+			parts = append(parts, "metric [x]")
+		} else {
+			parts = append(parts, "metric [ ]")
+		}
+	}
+
+	if postCmd != "" {
+		if postComplete {
+			parts = append(parts, "post [x]")
+		} else {
+			parts = append(parts, "post [ ]")
+		}
+	}
+
+	spacing := strings.Repeat(" ", max(width-len(label), 0)+1)
+
+	if len(parts) > 0 {
+		return fmt.Sprintf("%s:%s%s", label, spacing, strings.Join(parts, " ; "))
+	}
+	return fmt.Sprintf("%s:%smetric [ ]", label, spacing)
+}
+
+// progressLine tracks the steps completed on one side of a comparison
+type progressLine struct {
+	label      string // Ref or "HEAD", optionally prefixed with the metric name
+	width      int    // Label width used for alignment
+	pre        string // Pre command, if any
+	post       string // Post command, if any
+	preDone    bool
+	metricDone bool
+	postDone   bool
+	note       string         // Shown after the steps, e.g. where a skipped result came from
+	enabled    bool           // Whether the line is printed at all
+	out        io.Writer      // Where the line is printed
+	board      *progressBoard // Set when the line is redrawn alongside others
+}
+
+// newProgressLine creates a progress line for ref, printed to out unless out is
+// nil. When prefix is set (several metrics are being evaluated) the label
+// becomes "prefix (ref)".
+func newProgressLine(prefix string, ref string, m Metric, out io.Writer) *progressLine {
+	label := ref
+	if prefix != "" {
+		label = fmt.Sprintf("%s (%s)", prefix, ref)
+	}
+	return &progressLine{
+		label:   label,
+		width:   len(label),
+		pre:     m.Pre,
+		post:    m.Post,
+		enabled: out != nil,
+		out:     out,
+	}
+}
+
+func (p *progressLine) String() string {
+	line := buildProgressLine(p.label, p.width, p.pre, true, p.post, p.preDone, p.metricDone, p.postDone)
+	if p.note != "" {
+		line += " (" + p.note + ")"
+	}
+	return line
+}
+
+// start prints the initial state of the line
+func (p *progressLine) start() {
+	if p.enabled && p.board == nil {
+		fmt.Fprint(p.out, p)
+	}
+}
+
+// complete ticks off a step and redraws the line
+func (p *progressLine) complete(step string) {
+	if p.board != nil {
+		p.board.mu.Lock()
+		defer p.board.mu.Unlock()
+	}
+
+	switch step {
+	case "pre":
+		p.preDone = true
+	case "metric":
+		p.metricDone = true
+	case "post":
+		p.postDone = true
+	}
+
+	if p.board != nil {
+		p.board.redraw()
+	} else if p.enabled {
+		fmt.Fprintf(p.out, "\r%s", p)
+	}
+}
+
+// finish redraws the line in place and completes it
+func (p *progressLine) finish() {
+	if p.enabled && p.board == nil {
+		fmt.Fprintf(p.out, "\r%s\n", p)
+	}
+}
+
+// skip marks every step complete without running them, and prints the line
+// with note explaining where the result came from
+func (p *progressLine) skip(note string) {
+	p.preDone, p.metricDone, p.postDone = true, true, true
+	p.note = note
+	if p.enabled {
+		fmt.Fprintf(p.out, "%s\n", p)
+	}
+}
+
+// pending prints the line for a side that will not run, followed by a blank line
+func (p *progressLine) pending() {
+	if p.enabled {
+		fmt.Fprintf(p.out, "%s\n\n", p)
+	}
+}
+
+// blank separates the progress lines from the messages that follow
+func (p *progressLine) blank() {
+	if p.enabled {
+		fmt.Fprintln(p.out)
+	}
+}
+
+// progressBoard keeps several progress lines on screen and redraws them all in
+// place, so that each can be updated independently while steps run concurrently
+type progressBoard struct {
+	mu    sync.Mutex
+	lines []*progressLine
+	drawn bool
+}
+
+// draw prints the current state of every line
+func (b *progressBoard) draw() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.redraw()
+}
+
+// redraw moves the cursor back over the lines and prints them again. The
+// caller must hold b.mu.
+func (b *progressBoard) redraw() {
+	if b.drawn {
+		fmt.Fprintf(b.lines[0].out, "\033[%dA", len(b.lines))
+	}
+	for _, line := range b.lines {
+		fmt.Fprintf(line.out, "\r%s\033[K\n", line)
+	}
+	b.drawn = true
+}
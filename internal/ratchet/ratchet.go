@@ -1,15 +1,24 @@
 package ratchet
 
 import (
+	"context"
+	"errors"
 	"fmt"
+	"io"
 	"os"
 	"os/signal"
 	"strings"
+	"sync"
 	"syscall"
 
+	"github.com/tiernacity/ratchet/internal/baseline"
+	"github.com/tiernacity/ratchet/internal/cache"
+	"github.com/tiernacity/ratchet/internal/count"
+	"github.com/tiernacity/ratchet/internal/coverage"
 	"github.com/tiernacity/ratchet/internal/executor"
 	"github.com/tiernacity/ratchet/internal/git"
 	"github.com/tiernacity/ratchet/internal/parser"
+	"github.com/tiernacity/ratchet/internal/sarif"
 )
 
 // ComparisonType represents the type of comparison to perform
@@ -30,14 +39,131 @@ const (
 	GreaterThan
 )
 
+// Metric describes a single metric to evaluate
+type Metric struct {
+	Name           string           // Name identifying the metric in reports
+	Command        string           // Command to execute that outputs a number
+	BaseRef        string           // Base branch/ref to compare against
+	ComparisonType ComparisonType   // Type of comparison to perform
+	Pre            string           // Command to run before metric command
+	Post           string           // Command to run after metric command, even if it fails
+	Tolerance      Tolerance        // Allowance by which HEAD may be worse than base
+	Timeouts       Timeouts         // Limits on how long each step may run
+	Extractor      parser.Extractor // How the value is found in the metric output
+
+	// Items, if set, makes the output a list of items, one per line, whose
+	// count is the value; items added and removed since the base are reported
+	Items     bool
+	Normalize parser.Normalizer // How items are rewritten before they are compared
+
+	// Keys, if enabled, makes the output "key<TAB>number" lines whose sum is
+	// the value; each key must also pass the comparison
+	Keys KeyOptions
+
+	// SARIF, if set, is the SARIF log the metric command writes, relative to
+	// the working directory, or "-" for stdout. The value is the number of
+	// results, and the comparison fails only if HEAD has results the base does
+	// not. SARIFOutput, if set, receives HEAD's log with only those results.
+	SARIF       string
+	SARIFOutput string
+
+	// Coverage, if set, is the Go cover profile or LCOV trace the metric
+	// command writes, relative to the working directory, or "-" for stdout.
+	// The value is the overall coverage percentage, unless ChangedLines makes
+	// HEAD's value the coverage of the lines changed since the merge-base of
+	// HEAD and the base ref.
+	Coverage     string
+	ChangedLines bool
+
+	// Threshold, if set, is compared with HEAD instead of the base's value
+	Threshold *float64
+
+	// Count, if set, replaces the metric command with a built-in count of
+	// matching lines; Command then describes it. With Keys the count is
+	// broken down per file, and with Items each matching line is an item.
+	Count *count.Counter
+
+	// BaseFailure is what happens when the metric cannot be measured on the
+	// base; BaseDefault is the value compared against with BaseFailureDefault.
+	// BaseHelpers are files copied from the working copy into the base
+	// worktree before the base is measured.
+	BaseFailure BaseFailure
+	BaseDefault float64
+	BaseHelpers []string
+}
+
 // Options contains the configuration for running ratchet
 type Options struct {
-	Metric         string         // Command to execute that outputs a number
-	BaseRef        string         // Base branch/ref to compare against
-	ComparisonType ComparisonType // Type of comparison to perform
-	Pre            string         // Command to run before metric command
-	Post           string         // Command to run after metric command
-	Verbose        bool           // Show detailed output
+	Metrics    []Metric // Metrics to evaluate, sharing one worktree per base ref
+	Verbose    bool     // Show detailed output
+	Parallel   bool     // Measure base and HEAD at the same time
+	MergeBase  bool     // Measure the merge-base of HEAD and each base ref, not its tip
+	Baseline   string   // Baseline file to take base values from instead of base refs, if set
+	TrustedRef string   // Ref to read the baseline file from instead of the working copy, if set
+	Tighten    TightenOptions
+	Cache      *cache.Cache // Cache of base results, nil to always measure
+	Notes      NotesOptions // Recording and reuse of values in git notes
+	Output     io.Writer    // Destination for human-readable output, os.Stdout if nil
+
+	// OnEvaluated, if set, is called after each metric is evaluated and before
+	// the outcome is reported
+	OnEvaluated func(Result)
+}
+
+// TightenOptions controls rewriting the stored baseline after a successful
+// run: the baseline file in baseline mode, otherwise the thresholds set in
+// ConfigFile
+type TightenOptions struct {
+	Enabled    bool   // Record HEAD values that improve on the baseline
+	Commit     bool   // Commit the rewritten file
+	ConfigFile string // Config file whose thresholds are tightened outside baseline mode
+}
+
+// NotesOptions controls how metric values are stored in git notes
+type NotesOptions struct {
+	Read   bool   // Take base values from notes on the base commit when present
+	Write  bool   // Record measured values as notes on the commits they came from
+	Remote string // Remote to fetch notes from before, and push them to after, the run
+}
+
+// Result holds the outcome of evaluating a single metric
+type Result struct {
+	Metric     Metric
+	BaseCommit string // Resolved base commit SHA, empty without a comparison
+	HeadCommit string // Commit SHA that HEAD pointed to
+	HeadRef    string // Branch name the working copy is reported as
+	BaseOrigin string // Where the base value came from: "measured", "cached" or "git note"
+	BaseValue  float64
+	HeadValue  float64
+	Measured   bool          // Whether every value needed was obtained
+	Passed     bool          // Whether the comparison passed
+	Limit      float64       // Worst HEAD value that passes, after applying tolerance
+	Margin     float64       // Distance from HEAD to the limit, negative when failing
+	Steps      []StepResult  // Commands run on each side, in order
+	Items      *ItemDiff     // Items added and removed, for an item metric whose base output is known
+	Keys       []KeyChange   // Keys that failed the comparison, in key mode
+	Findings   *FindingDiff  // SARIF results new in HEAD, for a SARIF metric whose base log is known
+	Coverage   *LineCoverage // Coverage of changed lines, when HEAD's value is measured on them
+	Override   *Override     // Trailer that let the failed comparison pass, if any
+	BaseErr    error         // Why the base could not be measured, if the metric's base failure policy tolerated it
+	Err        error         // Set when the metric could not be evaluated
+}
+
+// LineCoverage describes how well the lines changed since the base are covered
+type LineCoverage struct {
+	Coverable int             // Changed lines that the profile says can be run
+	Uncovered []coverage.Line // Changed lines that were not run, in file and line order
+}
+
+// FindingDiff lists the SARIF results in HEAD that have no match in the base
+type FindingDiff struct {
+	New []sarif.Finding
+}
+
+// ItemDiff lists the items that differ between the base and HEAD
+type ItemDiff struct {
+	Added   []string // In HEAD but not the base
+	Removed []string // In the base but not HEAD
 }
 
 func (ct ComparisonType) String() string {
@@ -57,51 +183,36 @@ func (ct ComparisonType) String() string {
 	}
 }
 
-// buildProgressLine creates a progress line with checkboxes
-func buildProgressLine(branchName string, baseRef string, preCmd string, hasMetric bool, postCmd string, preComplete bool, metricComplete bool, postComplete bool) string {
-	var parts []string
-
-	if preCmd != "" {
-		if preComplete {
-			parts = append(parts, "pre [x]")
-		} else {
-			parts = append(parts, "pre [ ]")
-		}
-	}
-
-	if hasMetric {
-		if metricComplete {
-			parts = append(parts, "metric [x]")
-		} else {
-			parts = append(parts, "metric [ ]")
-		}
-	}
-
-	if postCmd != "" {
-		if postComplete {
-			parts = append(parts, "post [x]")
-		} else {
-			parts = append(parts, "post [ ]")
-		}
-	}
-
-	// Create proper spacing - align based on the longer of baseRef or "HEAD"
-	maxBranchLen := len(baseRef)
-	if len("HEAD") > maxBranchLen {
-		maxBranchLen = len("HEAD")
-	}
-	spacing := strings.Repeat(" ", maxBranchLen-len(branchName)+1)
-
-	if len(parts) > 0 {
-		return fmt.Sprintf("%s:%s%s", branchName, spacing, strings.Join(parts, " ; "))
+// Symbol returns the operator used to display the comparison in tables
+func (ct ComparisonType) Symbol() string {
+	switch ct {
+	case LessThan:
+		return "<"
+	case LessEqual:
+		return "<="
+	case Equal:
+		return "=="
+	case GreaterEqual:
+		return ">="
+	case GreaterThan:
+		return ">"
+	default:
+		return "-"
 	}
-	return fmt.Sprintf("%s:%smetric [ ]", branchName, spacing)
 }
 
-func Run(opts Options) error {
+// Run evaluates every metric in opts, reports the outcome and returns the
+// result for each metric. The error is non-nil if any metric failed or could
+// not be evaluated; results are nil if the run could not start at all.
+func Run(opts Options) ([]Result, error) {
 	// Check if we're in a git repository
 	if !git.IsGitRepository() {
-		return fmt.Errorf("not a git repository")
+		return nil, &GitError{Err: fmt.Errorf("not a git repository")}
+	}
+
+	out := opts.Output
+	if out == nil {
+		out = os.Stdout
 	}
 
 	// Get current branch name for display
@@ -111,236 +222,591 @@ func Run(opts Options) error {
 	}
 
 	// Set up signal handling for graceful cleanup at the start
-	var cleanup func()
-	sigChan := make(chan os.Signal, 1)
-	signal.Notify(sigChan, syscall.SIGINT, syscall.SIGTERM)
-	go func() {
-		<-sigChan
-		if cleanup != nil {
-			cleanup()
+	cleanups := newCleanupSet()
+	defer cleanups.runAll()
+
+	// Bring in notes recorded elsewhere before looking for base values
+	if opts.Notes.Remote != "" && (opts.Notes.Read || opts.Notes.Write) {
+		if err := git.FetchNotes(opts.Notes.Remote); err != nil {
+			fmt.Fprintf(os.Stderr, "Warning: %v\n", err)
+		}
+	}
+
+	// Refuse to tighten before anything runs if other files are modified or
+	// untracked, since HEAD values measured from uncommitted code must not be
+	// recorded
+	if opts.Tighten.Enabled {
+		stored := opts.tightenedFile()
+		dirty, err := git.ChangedFiles(stored)
+		if err != nil {
+			return nil, &GitError{Err: err}
+		}
+		if len(dirty) > 0 {
+			return nil, fmt.Errorf("refusing to tighten %s because the working copy has uncommitted changes to %s", stored, strings.Join(dirty, ", "))
+		}
+	}
+
+	// In baseline mode every base value comes from the committed file
+	var baselines *baseline.File
+	if opts.Baseline != "" {
+		if baselines, err = opts.loadBaseline(); err != nil {
+			return nil, err
+		}
+	}
+
+	// Work out where each base value comes from, using notes and cached results
+	// where possible so that a worktree is only created if something must be measured
+	sources := make([]baseSource, len(opts.Metrics))
+	mergeBases := mergeBaseResolver{}
+	for i, m := range opts.Metrics {
+		if m.ComparisonType == NoComparison {
+			continue
+		}
+
+		if baselines != nil {
+			value, ok := baselines.Metrics[m.key()]
+			if !ok {
+				return nil, fmt.Errorf("no baseline for metric '%s' in %s; record one with ratchet update-baseline", m.key(), opts.Baseline)
+			}
+			sources[i].value = value
+			sources[i].known = true
+			sources[i].valued = true
+			sources[i].origin = "baseline"
+			continue
 		}
-		os.Exit(130) // Standard exit code for SIGINT
-	}()
 
-	// Only create worktree if we need to compare
-	var baseValue float64
-	var baseProgressShown bool
-	if opts.ComparisonType != NoComparison {
 		// Ensure base branch exists
-		if err := git.EnsureBranchExists(opts.BaseRef); err != nil {
-			return fmt.Errorf("base branch '%s' not found", opts.BaseRef)
+		if err := git.EnsureBranchExists(m.BaseRef); err != nil {
+			return nil, &GitError{Err: fmt.Errorf("base branch '%s' not found", m.BaseRef)}
 		}
 
-		// Create temporary worktree for base branch
-		worktreePath, cleanupFunc, err := git.CreateWorktree(opts.BaseRef)
+		commit, err := git.ResolveCommit(m.BaseRef)
 		if err != nil {
-			return fmt.Errorf("failed to create worktree for branch '%s'", opts.BaseRef)
-		}
-		cleanup = cleanupFunc
-		defer cleanup()
-
-		// Show initial progress line for base branch (only if verbose)
-		if opts.Verbose {
-			progress := buildProgressLine(opts.BaseRef, opts.BaseRef, opts.Pre, true, opts.Post, false, false, false)
-			fmt.Print(progress)
-			baseProgressShown = true
-		}
-
-		// Run pre command in base branch if specified
-		preCompleted := true
-		if opts.Pre != "" {
-			if _, err := executor.Execute(opts.Pre, worktreePath); err != nil {
-				// Show final progress state and exit (only if verbose)
-				if opts.Verbose {
-					progress := buildProgressLine(opts.BaseRef, opts.BaseRef, opts.Pre, true, opts.Post, false, false, false)
-					fmt.Printf("\r%s\n", progress)
-					progress = buildProgressLine("HEAD", opts.BaseRef, opts.Pre, true, opts.Post, false, false, false)
-					fmt.Printf("%s\n\n", progress)
-				}
-				fmt.Fprintf(os.Stderr, "Command '%s' failed in %s\n", opts.Pre, opts.BaseRef)
-				fmt.Fprintln(os.Stderr, "Failed")
-				return fmt.Errorf("metric test failed")
+			return nil, &GitError{Err: fmt.Errorf("base branch '%s' not found", m.BaseRef)}
+		}
+		sources[i].checkout = m.BaseRef
+		if opts.MergeBase {
+			tip := commit
+			if commit, err = mergeBases.resolve(m.BaseRef, tip); err != nil {
+				return nil, &GitError{Err: err}
 			}
-			// Update progress to show pre command completed (only if verbose)
-			if opts.Verbose {
-				progress := buildProgressLine(opts.BaseRef, opts.BaseRef, opts.Pre, true, opts.Post, true, false, false)
-				fmt.Printf("\r%s", progress)
+			sources[i].checkout = commit
+		}
+		sources[i].commit = commit
+		sources[i].origin = "measured"
+
+		if m.Threshold != nil {
+			sources[i].value = *m.Threshold
+			sources[i].known = true
+			sources[i].valued = true
+			sources[i].origin = "threshold"
+			continue
+		}
+
+		if opts.Notes.Read {
+			values, err := git.ReadNote(commit)
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "Warning: %v\n", err)
+			} else if value, ok := values[m.key()]; ok {
+				sources[i].value = value
+				sources[i].known = true
+				sources[i].valued = true
+				sources[i].origin = "git note"
+				continue
 			}
 		}
 
-		// Execute command in base branch
-		baseOutput, err := executor.Execute(opts.Metric, worktreePath)
+		if opts.Cache != nil {
+			commands, err := m.baseCommands()
+			if err != nil {
+				return nil, err
+			}
+			sources[i].cacheKey = cache.Key(commit, commands...)
+			if output, ok := opts.Cache.Get(sources[i].cacheKey); ok {
+				sources[i].output = output
+				sources[i].known = true
+				sources[i].origin = "cached"
+			}
+		}
+	}
+
+	// Create one worktree per base ref, shared by every metric measured
+	// against it. A metric with base helpers gets a worktree of its own, since
+	// HEAD's scripts copied into a shared one would change what the other
+	// metrics measure.
+	worktrees := make(map[string]string)
+	for i, m := range opts.Metrics {
+		if m.ComparisonType == NoComparison || sources[i].known {
+			continue
+		}
+		checkout := sources[i].checkout
+		shared := len(m.BaseHelpers) == 0
+		if path, ok := worktrees[checkout]; ok && shared {
+			sources[i].dir = path
+			continue
+		}
+
+		// Create temporary worktree for base branch
+		worktreePath, cleanupFunc, err := git.CreateWorktree(checkout)
 		if err != nil {
-			// Show final progress state and exit (only if verbose)
-			if opts.Verbose {
-				progress := buildProgressLine(opts.BaseRef, opts.BaseRef, opts.Pre, true, opts.Post, preCompleted, false, false)
-				fmt.Printf("\r%s\n", progress)
-				progress = buildProgressLine("HEAD", opts.BaseRef, opts.Pre, true, opts.Post, false, false, false)
-				fmt.Printf("%s\n\n", progress)
+			return nil, &GitError{Err: fmt.Errorf("failed to create worktree for branch '%s'", m.BaseRef)}
+		}
+		cleanups.add(cleanupFunc)
+		sources[i].dir = worktreePath
+		if !shared {
+			// Measure the base with HEAD's version of the helper scripts
+			if err := copyHelpers(worktreePath, m.BaseHelpers); err != nil {
+				return nil, err
 			}
-			fmt.Fprintf(os.Stderr, "Metric command '%s' failed in %s\n", opts.Metric, opts.BaseRef)
-			fmt.Fprintln(os.Stderr, "Failed")
-			return fmt.Errorf("metric test failed")
-		}
-		// Update progress to show metric command completed (only if verbose)
-		if opts.Verbose {
-			progress := buildProgressLine(opts.BaseRef, opts.BaseRef, opts.Pre, true, opts.Post, preCompleted, true, false)
-			fmt.Printf("\r%s", progress)
-		}
-
-		// Run post command in base branch if specified
-		postCompleted := true
-		if opts.Post != "" {
-			if _, err := executor.Execute(opts.Post, worktreePath); err != nil {
-				// Show final progress state and exit (only if verbose)
-				if opts.Verbose {
-					progress := buildProgressLine(opts.BaseRef, opts.BaseRef, opts.Pre, true, opts.Post, preCompleted, true, false)
-					fmt.Printf("\r%s\n", progress)
-					progress = buildProgressLine("HEAD", opts.BaseRef, opts.Pre, true, opts.Post, false, false, false)
-					fmt.Printf("%s\n\n", progress)
-				}
-				fmt.Fprintf(os.Stderr, "Command '%s' failed in %s\n", opts.Post, opts.BaseRef)
-				fmt.Fprintln(os.Stderr, "Failed")
-				return fmt.Errorf("metric test failed")
+			continue
+		}
+		worktrees[checkout] = worktreePath
+	}
+
+	// HEAD may not resolve in a repository without commits
+	headCommit, err := git.HeadCommit()
+	if err != nil {
+		headCommit = ""
+	}
+
+	// A baseline file has no base ref whose branch could carry trailers
+	overrides := allowances{}
+	allow := func(res *Result) {
+		if opts.Baseline == "" {
+			overrides.apply(res)
+		}
+	}
+
+	var results []Result
+	if len(opts.Metrics) == 1 {
+		res := evaluate(cleanups.ctx, opts.Metrics[0], sources[0], currentBranch, "", out, opts)
+		res.HeadCommit = headCommit
+		allow(&res)
+		if opts.OnEvaluated != nil {
+			opts.OnEvaluated(res)
+		}
+		results = []Result{res}
+	} else {
+		for i, m := range opts.Metrics {
+			var res Result
+			if cleanups.ctx.Err() != nil {
+				// Interrupted: the remaining metrics are not started
+				res = Result{Metric: m, HeadRef: currentBranch, Err: executor.ErrInterrupted}
+			} else {
+				res = evaluate(cleanups.ctx, m, sources[i], currentBranch, m.Name, out, opts)
+			}
+			res.HeadCommit = headCommit
+			allow(&res)
+			if opts.OnEvaluated != nil {
+				opts.OnEvaluated(res)
 			}
-			// Update progress to show post command state (only if verbose)
-			if opts.Verbose {
-				progress := buildProgressLine(opts.BaseRef, opts.BaseRef, opts.Pre, true, opts.Post, preCompleted, true, postCompleted)
-				fmt.Printf("\r%s", progress)
+			var stepErr *StepError
+			if errors.As(res.Err, &stepErr) {
+				// Includes any post command that also failed
+				fmt.Fprintln(os.Stderr, res.Err)
+			} else if res.Err != nil {
+				fmt.Fprintf(os.Stderr, "%s: %v\n", m.Name, res.Err)
 			}
+			results = append(results, res)
 		}
+	}
+
+	if opts.Notes.Write {
+		recordNotes(results, sources, opts)
+	}
 
-		// Complete the base branch line (only if verbose)
-		if baseProgressShown {
-			fmt.Print("\n")
+	if len(results) == 1 {
+		err = reportSingle(out, results[0], opts.Verbose)
+	} else {
+		err = reportTable(out, results, opts.Verbose)
+	}
+	if err == nil && opts.Tighten.Enabled {
+		err = tighten(results, opts)
+	}
+	return results, err
+}
+
+// recordNotes stores the measured values as git notes on the base commits and,
+// if the working copy is clean, on HEAD; then pushes them if a remote is set
+func recordNotes(results []Result, sources []baseSource, opts Options) {
+	headCommit := ""
+	if dirty, err := git.HasUncommittedChanges(); err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: %v\n", err)
+	} else if dirty {
+		fmt.Fprintln(os.Stderr, "Warning: not recording HEAD values in git notes because the working copy has uncommitted changes")
+	} else if headCommit, err = git.HeadCommit(); err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: %v\n", err)
+	}
+
+	written := false
+	write := func(commit string, name string, value float64) {
+		if err := git.WriteNote(commit, name, value); err != nil {
+			fmt.Fprintf(os.Stderr, "Warning: %v\n", err)
+			return
+		}
+		written = true
+	}
+	for i, res := range results {
+		if res.Err != nil {
+			continue
+		}
+		m := res.Metric
+		// A base measured with HEAD's helpers, or not at all, is not the base's own value
+		if m.ComparisonType != NoComparison && !sources[i].known && res.BaseErr == nil && len(m.BaseHelpers) == 0 {
+			write(sources[i].commit, m.key(), res.BaseValue)
+		}
+		// With changed lines HEAD's value covers those lines alone, not the
+		// overall coverage that a later run would read as a base value
+		if headCommit != "" && !m.ChangedLines {
+			write(headCommit, m.key(), res.HeadValue)
+		}
+	}
+
+	if written && opts.Notes.Remote != "" {
+		if err := git.PushNotes(opts.Notes.Remote); err != nil {
+			fmt.Fprintf(os.Stderr, "Warning: %v\n", err)
 		}
+	}
+}
+
+// key returns the name the metric is recorded under, falling back to the
+// command for an unnamed metric
+func (m Metric) key() string {
+	if m.Name != "" {
+		return m.Name
+	}
+	return m.Command
+}
+
+// extract finds the value in output from the metric command run in where
+func (m Metric) extract(output string, where string) (float64, error) {
+	value, err := m.extractValue(output, where)
+	if err != nil {
+		return 0, &ParseError{Err: err}
+	}
+	return value, nil
+}
 
-		baseValue, err = parser.ParseNumber(baseOutput)
+// extractValue does the work of extract, according to the metric's mode
+func (m Metric) extractValue(output string, where string) (float64, error) {
+	if m.Items {
+		return float64(len(parser.Items(output, m.Normalize))), nil
+	}
+	if m.Coverage != "" {
+		profile, err := coverage.Parse(output)
+		if err != nil {
+			return 0, fmt.Errorf("could not read coverage from %s: %v", where, err)
+		}
+		return roundNoise(profile.Percent()), nil
+	}
+	if m.SARIF != "" {
+		findings, err := sarif.Parse(output)
 		if err != nil {
-			return fmt.Errorf("command output from %s is not a number: '%s'", opts.BaseRef, baseOutput)
+			return 0, fmt.Errorf("could not read SARIF from %s: %v", where, err)
 		}
+		return float64(len(findings)), nil
 	}
+	if m.Keys.Enabled {
+		values, _, err := parser.Keys(output)
+		if err != nil {
+			return 0, fmt.Errorf("could not read keys in output from %s: %v", where, err)
+		}
+		total := 0.0
+		for _, v := range values {
+			total += v
+		}
+		return roundNoise(total), nil
+	}
+	value, err := m.Extractor.Extract(output)
+	if err == nil {
+		return value, nil
+	}
+	if m.Extractor.Mode == parser.Whole {
+		return 0, fmt.Errorf("command output from %s is not a number: '%s'", where, parser.Snippet(output))
+	}
+	return 0, fmt.Errorf("could not find the metric in output from %s: %v", where, err)
+}
 
-	// Show progress line for current branch (only if we're doing comparison and verbose)
-	var currentProgressShown bool
-	if opts.ComparisonType != NoComparison && opts.Verbose {
-		progress := buildProgressLine("HEAD", opts.BaseRef, opts.Pre, true, opts.Post, false, false, false)
-		fmt.Print(progress)
-		currentProgressShown = true
+// commands returns the commands that determine the metric value
+func (m Metric) commands() []string {
+	if path := m.outputFile(); path != "" {
+		// The output is the file read from this path
+		return []string{m.Pre, m.Command, m.Post, path}
 	}
+	if m.Count != nil {
+		// The output depends on the format as well as the count
+		return []string{m.Pre, m.Command, m.Post, fmt.Sprintf("format %d", m.countFormat())}
+	}
+	return []string{m.Pre, m.Command, m.Post}
+}
 
-	// Run pre command in current branch if specified
-	preCompleted := true
-	if opts.Pre != "" {
-		if _, err := executor.Execute(opts.Pre, ""); err != nil {
-			if opts.ComparisonType != NoComparison && opts.Verbose {
-				// Show final progress state and exit
-				progress := buildProgressLine("HEAD", opts.BaseRef, opts.Pre, true, opts.Post, false, false, false)
-				fmt.Printf("\r%s\n\n", progress)
-			}
-			fmt.Fprintf(os.Stderr, "Command '%s' failed in %s\n", opts.Pre, currentBranch)
-			fmt.Fprintln(os.Stderr, "Failed")
-			return fmt.Errorf("metric test failed")
+// countFormat returns what a built-in count writes for the metric
+func (m Metric) countFormat() count.Format {
+	switch {
+	case m.Keys.Enabled:
+		return count.PerFile
+	case m.Items:
+		return count.Lines
+	default:
+		return count.Total
+	}
+}
+
+// outputFile returns the file the metric command writes that stands in for
+// its stdout, if any, or "-" for stdout
+func (m Metric) outputFile() string {
+	if m.SARIF != "" {
+		return m.SARIF
+	}
+	return m.Coverage
+}
+
+// cleanupSet holds cleanups, such as worktree removal, that must run before
+// ratchet exits, including when it is interrupted
+type cleanupSet struct {
+	ctx   context.Context // Cancelled if ratchet receives SIGINT or SIGTERM
+	stop  context.CancelFunc
+	mu    sync.Mutex
+	funcs []func() // In the order added, nil once run early
+}
+
+// newCleanupSet returns an empty set whose context is cancelled if ratchet
+// receives SIGINT or SIGTERM. Running commands are then stopped and post
+// commands run before the cleanups, rather than exiting at once; a second
+// signal is no longer caught here, so that a hung teardown can still be
+// stopped.
+func newCleanupSet() *cleanupSet {
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	c := &cleanupSet{ctx: ctx, stop: stop}
+	go func() {
+		<-ctx.Done()
+		stop()
+	}()
+	return c
+}
+
+// add registers f and returns a function that runs it early and forgets it
+func (c *cleanupSet) add(f func()) func() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	i := len(c.funcs)
+	c.funcs = append(c.funcs, f)
+	return func() {
+		c.mu.Lock()
+		f := c.funcs[i]
+		c.funcs[i] = nil
+		c.mu.Unlock()
+		if f != nil {
+			f()
 		}
-		if opts.ComparisonType != NoComparison && opts.Verbose {
-			// Update progress to show pre command completed
-			progress := buildProgressLine("HEAD", opts.BaseRef, opts.Pre, true, opts.Post, true, false, false)
-			fmt.Printf("\r%s", progress)
+	}
+}
+
+// runAll runs every registered cleanup, the most recently added first so that
+// anything set up on top of an earlier resource is gone before it is, and
+// stops watching for signals
+func (c *cleanupSet) runAll() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for i := len(c.funcs) - 1; i >= 0; i-- {
+		if f := c.funcs[i]; f != nil {
+			c.funcs[i] = nil
+			f()
 		}
 	}
+	c.stop()
+}
+
+// mergeBaseResolver finds the merge-base of HEAD and each base ref, explaining
+// the choice once per ref
+type mergeBaseResolver map[string]string
 
-	// Get current branch value
-	currentOutput, err := executor.Execute(opts.Metric, "")
+// resolve returns the merge-base of HEAD and ref, whose tip is at tip
+func (r mergeBaseResolver) resolve(ref string, tip string) (string, error) {
+	if commit, ok := r[ref]; ok {
+		return commit, nil
+	}
+	commit, err := git.MergeBase(ref)
 	if err != nil {
-		if opts.ComparisonType != NoComparison && opts.Verbose {
-			// Show final progress state and exit
-			progress := buildProgressLine("HEAD", opts.BaseRef, opts.Pre, true, opts.Post, preCompleted, false, false)
-			fmt.Printf("\r%s\n\n", progress)
-		}
-		fmt.Fprintf(os.Stderr, "Metric command '%s' failed in %s\n", opts.Metric, currentBranch)
-		fmt.Fprintln(os.Stderr, "Failed")
-		return fmt.Errorf("metric test failed")
-	}
-	if opts.ComparisonType != NoComparison && opts.Verbose {
-		// Update progress to show metric command completed
-		progress := buildProgressLine("HEAD", opts.BaseRef, opts.Pre, true, opts.Post, preCompleted, true, false)
-		fmt.Printf("\r%s", progress)
-	}
-
-	// Run post command in current branch if specified
-	postCompleted := true
-	if opts.Post != "" {
-		if _, err := executor.Execute(opts.Post, ""); err != nil {
-			if opts.ComparisonType != NoComparison && opts.Verbose {
-				// Show final progress state and exit
-				progress := buildProgressLine("HEAD", opts.BaseRef, opts.Pre, true, opts.Post, preCompleted, true, false)
-				fmt.Printf("\r%s\n\n", progress)
+		return "", fmt.Errorf("failed to find merge-base of HEAD and '%s': %w", ref, err)
+	}
+	r[ref] = commit
+
+	if commit == tip {
+		fmt.Fprintf(os.Stderr, "Comparing against merge-base %s of HEAD and %s, which is the tip of %s\n",
+			shortSHA(commit), ref, ref)
+	} else {
+		fmt.Fprintf(os.Stderr, "Comparing against merge-base %s of HEAD and %s instead of its tip %s, "+
+			"so changes made to %s since this branch forked are not counted\n",
+			shortSHA(commit), ref, shortSHA(tip), ref)
+	}
+	return commit, nil
+}
+
+// shortSHA abbreviates a commit SHA for display
+func shortSHA(commit string) string {
+	if len(commit) > 10 {
+		return commit[:10]
+	}
+	return commit
+}
+
+// baseSource describes where the base value of a metric comes from
+type baseSource struct {
+	dir      string  // Worktree to run the pipeline in
+	checkout string  // Ref or commit to check out in the worktree
+	commit   string  // Resolved base commit, if known
+	cacheKey string  // Key to store the result under, empty if not caching
+	output   string  // Metric output obtained without running the pipeline
+	value    float64 // Value obtained without running the pipeline or extracting it
+	known    bool    // Whether output or value is already known
+	valued   bool    // Whether value, rather than output, is known
+	origin   string  // Where a known output or value came from, shown in progress
+}
+
+// evaluate measures the metric on the base (if comparing) and the working
+// copy, then applies the comparison. Commands are stopped if ctx is
+// cancelled. Progress lines are labelled with prefix when several metrics
+// are evaluated together.
+func evaluate(ctx context.Context, m Metric, src baseSource, currentBranch string, prefix string, out io.Writer, opts Options) Result {
+	res := Result{Metric: m, HeadRef: currentBranch}
+	compare := m.ComparisonType != NoComparison
+
+	// Progress is only shown when comparing, and only if verbose
+	var progressOut io.Writer
+	if compare && opts.Verbose {
+		progressOut = out
+	}
+	baseLine := newProgressLine(prefix, m.BaseRef, m, progressOut)
+	headLine := newProgressLine(prefix, "HEAD", m, progressOut)
+	baseLine.width = max(baseLine.width, len(headLine.label))
+	headLine.width = baseLine.width
+	base := side{name: SideBase, dir: src.dir, where: m.BaseRef, line: baseLine}
+	head := side{name: SideHead, where: currentBranch, line: headLine}
+
+	var baseOutput, currentOutput string
+	var steps []StepResult
+	var baseErr, err error
+	headDone := false
+	if compare {
+		res.BaseCommit = src.commit
+		res.BaseOrigin = src.origin
+
+		switch {
+		case src.known:
+			baseLine.skip(src.origin)
+			baseOutput = src.output
+		case opts.Parallel:
+			baseOutput, currentOutput, steps, baseErr, err = runParallel(ctx, m, base, head)
+			res.Steps = append(res.Steps, steps...)
+			if err != nil {
+				headLine.blank()
+				res.Err = err
+				return res
+			}
+			headDone = true
+		default:
+			baseOutput, steps, err = runPipeline(ctx, m, base)
+			res.Steps = append(res.Steps, steps...)
+			if err != nil && m.BaseFailure == BaseFailureFail {
+				headLine.pending()
+				res.Err = err
+				return res
 			}
-			fmt.Fprintf(os.Stderr, "Command '%s' failed in %s\n", opts.Post, currentBranch)
-			fmt.Fprintln(os.Stderr, "Failed")
-			return fmt.Errorf("metric test failed")
+			baseErr = err
 		}
-		if opts.ComparisonType != NoComparison && opts.Verbose {
-			// Update progress to show post command state
-			progress := buildProgressLine("HEAD", opts.BaseRef, opts.Pre, true, opts.Post, preCompleted, true, postCompleted)
-			fmt.Printf("\r%s", progress)
+
+		if baseErr == nil {
+			if src.valued {
+				res.BaseValue = src.value
+			} else if res.BaseValue, err = m.extract(baseOutput, m.BaseRef); err != nil {
+				if m.BaseFailure == BaseFailureFail {
+					res.Err = err
+					return res
+				}
+				baseErr = err
+			}
 		}
-	}
 
-	// Complete the current branch line
-	if currentProgressShown {
-		fmt.Print("\n")
+		// The policy decides what a base that cannot be measured is worth. Like
+		// a value from a note, a default has no output to compare in detail.
+		if baseErr != nil {
+			res.BaseErr = baseErr
+			src.valued = true
+			if m.BaseFailure == BaseFailureDefault {
+				res.BaseValue = m.BaseDefault
+				res.BaseOrigin = "default"
+				src.origin = "default"
+				fmt.Fprintf(os.Stderr, "Warning: %v; comparing %s against the default %g\n", baseErr, m.key(), m.BaseDefault)
+			} else {
+				res.BaseOrigin = "failed"
+				fmt.Fprintf(os.Stderr, "Warning: %v; not comparing %s\n", baseErr, m.key())
+			}
+		} else if opts.Cache != nil && !src.known {
+			// Remember the result for next time
+			if err := opts.Cache.Put(src.cacheKey, src.commit, m.commands(), baseOutput); err != nil {
+				fmt.Fprintf(os.Stderr, "Warning: %v\n", err)
+			}
+		}
 	}
 
-	currentValue, err := parser.ParseNumber(currentOutput)
+	if !headDone {
+		currentOutput, steps, err = runPipeline(ctx, m, head)
+		res.Steps = append(res.Steps, steps...)
+		if err != nil {
+			headLine.blank()
+			res.Err = err
+			return res
+		}
+	}
+	res.HeadValue, err = m.extract(currentOutput, currentBranch)
 	if err != nil {
-		return fmt.Errorf("command output from %s is not a number: '%s'", currentBranch, currentOutput)
+		res.Err = err
+		return res
+	}
+	if compare && m.ChangedLines {
+		if res.Coverage, res.HeadValue, err = changedLineCoverage(m, currentOutput); err != nil {
+			res.Err = err
+			return res
+		}
 	}
 
-	// If no comparison, just output the metric
-	if opts.ComparisonType == NoComparison {
-		fmt.Printf("%g\n", currentValue)
-		return nil
+	res.Measured = true
+	if res.BaseErr != nil && m.BaseFailure == BaseFailureWarn {
+		res.Passed = true
+		return res
 	}
+	res.Passed, res.Limit, res.Margin = check(m.ComparisonType, res.HeadValue, res.BaseValue, m.Tolerance)
 
-	// Perform comparison based on type
-	var passed bool
-	var comparisonText string
-	switch opts.ComparisonType {
-	case LessThan:
-		passed = currentValue < baseValue
-		comparisonText = "less than"
-	case LessEqual:
-		passed = currentValue <= baseValue
-		comparisonText = "less than or equal to"
-	case Equal:
-		passed = currentValue == baseValue
-		comparisonText = "equal to"
-	case GreaterEqual:
-		passed = currentValue >= baseValue
-		comparisonText = "greater than or equal to"
-	case GreaterThan:
-		passed = currentValue > baseValue
-		comparisonText = "greater than"
+	// Items can only be compared if the base output was run or cached, not
+	// when just its value was stored
+	if compare && m.Items && !src.valued {
+		added, removed := parser.DiffItems(parser.Items(baseOutput, m.Normalize), parser.Items(currentOutput, m.Normalize))
+		res.Items = &ItemDiff{Added: added, Removed: removed}
 	}
 
-	if passed {
-		// Only show detailed status line if verbose (for passing tests)
-		if opts.Verbose {
-			// Add blank line before result
-			fmt.Println()
-			fmt.Printf("%s metric (%g) is %s %s (%g)\n", currentBranch, currentValue, comparisonText, opts.BaseRef, baseValue)
+	// A SARIF metric fails on new results alone, whatever the counts
+	if compare && m.SARIF != "" {
+		if src.valued {
+			fmt.Fprintf(os.Stderr, "Warning: comparing only the number of results of %s, since its base value came from %s\n", m.key(), src.origin)
+		} else if res.Findings, err = compareFindings(m, baseOutput, currentOutput); err != nil {
+			res.Err = err
+			res.Measured = false
+		} else {
+			res.Passed = len(res.Findings.New) == 0
 		}
-		fmt.Println("Succeeded")
-		return nil
 	}
 
-	// Test failed - always show status line for failures
-	// Add blank line before result if verbose (since progress lines were shown)
-	if opts.Verbose {
-		fmt.Println()
+	// Likewise each key can only be compared if the base output is known
+	if compare && m.Keys.Enabled {
+		if src.valued {
+			fmt.Fprintf(os.Stderr, "Warning: comparing only the total of %s, since its base value came from %s\n", m.key(), src.origin)
+		} else if res.Keys, err = compareKeys(m, baseOutput, currentOutput); err != nil {
+			res.Err = err
+			res.Measured = false
+		} else if len(res.Keys) > 0 {
+			res.Passed = false
+		}
 	}
-	fmt.Fprintf(os.Stderr, "%s metric (%g) is NOT %s %s (%g)\n", currentBranch, currentValue, comparisonText, opts.BaseRef, baseValue)
-	fmt.Fprintln(os.Stderr, "Failed")
-	return fmt.Errorf("metric test failed")
+	return res
 }
@@ -0,0 +1,117 @@
+package ratchet
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/tiernacity/ratchet/internal/executor"
+)
+
+func TestPostRunsAfterFailure(t *testing.T) {
+	tests := []struct {
+		name    string
+		pre     string
+		command string
+		post    string
+		cancel  bool
+		steps   []string // Steps run, in order
+		errs    []string // Steps whose failures are reported
+	}{
+		{name: "pre fails", pre: "exit 1", command: "echo 1", post: "touch post", steps: []string{"pre", "post"}, errs: []string{"pre"}},
+		{name: "metric fails", pre: "true", command: "exit 3", post: "touch post", steps: []string{"pre", "metric", "post"}, errs: []string{"metric"}},
+		{name: "interrupted", command: "sleep 30", post: "touch post", cancel: true, steps: []string{"metric", "post"}, errs: []string{"metric"}},
+		{name: "post fails too", pre: "exit 1", command: "echo 1", post: "touch post; exit 2", steps: []string{"pre", "post"}, errs: []string{"pre", "post"}},
+		{name: "only post fails", command: "echo 1", post: "touch post; exit 2", steps: []string{"metric", "post"}, errs: []string{"post"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m := Metric{Pre: tt.pre, Command: tt.command, Post: tt.post}
+			dir := t.TempDir()
+			s := side{name: SideHead, dir: dir, where: "working copy", line: newProgressLine("", "HEAD", m, nil)}
+
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+			if tt.cancel {
+				time.AfterFunc(200*time.Millisecond, cancel)
+			}
+			_, steps, err := runPipeline(ctx, m, s)
+
+			if _, statErr := os.Stat(filepath.Join(dir, "post")); statErr != nil {
+				t.Error("post command did not run")
+			}
+			var run []string
+			for _, step := range steps {
+				run = append(run, step.Step)
+			}
+			if !reflect.DeepEqual(run, tt.steps) {
+				t.Errorf("ran steps %v, want %v", run, tt.steps)
+			}
+
+			// Each failure is kept, joined if post failed as well
+			var failed []string
+			errs := []error{err}
+			if joined, ok := err.(interface{ Unwrap() []error }); ok {
+				errs = joined.Unwrap()
+			}
+			for _, e := range errs {
+				if e == nil {
+					continue
+				}
+				var stepErr *StepError
+				if !errors.As(e, &stepErr) {
+					t.Fatalf("got error %v, want step errors", e)
+				}
+				failed = append(failed, stepErr.Step)
+			}
+			if !reflect.DeepEqual(failed, tt.errs) {
+				t.Errorf("reported failures of %v, want %v (error %v)", failed, tt.errs, err)
+			}
+			if tt.cancel && !errors.Is(err, executor.ErrInterrupted) {
+				t.Errorf("got error %v, want it to say the metric was interrupted", err)
+			}
+			if len(tt.errs) == 2 && strings.Count(err.Error(), "\n") != 1 {
+				t.Errorf("error %q does not give each failure on its own line", err)
+			}
+		})
+	}
+}
+
+func TestPostNotRunBeforeStart(t *testing.T) {
+	m := Metric{Command: "echo 1", Post: "touch post"}
+	dir := t.TempDir()
+	s := side{name: SideHead, dir: dir, where: "working copy", line: newProgressLine("", "HEAD", m, nil)}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	_, steps, err := runPipeline(ctx, m, s)
+	if !errors.Is(err, executor.ErrInterrupted) || len(steps) > 0 {
+		t.Errorf("got steps %v and error %v, want nothing run", steps, err)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "post")); err == nil {
+		t.Error("post command ran although nothing had started")
+	}
+}
+
+func TestCleanupsRunInReverse(t *testing.T) {
+	c := newCleanupSet()
+	var order []int
+	for i := range 4 {
+		remove := c.add(func() { order = append(order, i) })
+		if i == 1 {
+			remove()
+			remove()
+		}
+	}
+	c.runAll()
+	c.runAll()
+	if want := []int{1, 3, 2, 0}; !reflect.DeepEqual(order, want) {
+		t.Errorf("cleanups ran in order %v, want %v", order, want)
+	}
+}
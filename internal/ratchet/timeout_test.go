@@ -0,0 +1,49 @@
+package ratchet
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestStepTimeout(t *testing.T) {
+	initRepo(t)
+	late := filepath.Join(t.TempDir(), "late")
+	m := Metric{
+		Command:        fmt.Sprintf("sleep 1 && touch %s; echo 1", filepath.ToSlash(late)),
+		BaseRef:        "main",
+		ComparisonType: LessEqual,
+		Timeouts:       Timeouts{Metric: 200 * time.Millisecond},
+	}
+
+	start := time.Now()
+	results, err := Run(Options{Metrics: []Metric{m}, Output: &bytes.Buffer{}})
+	if elapsed := time.Since(start); elapsed > 900*time.Millisecond {
+		t.Errorf("run took %s, want the step stopped after 200ms", elapsed)
+	}
+	if err == nil {
+		t.Fatal("expected the run to fail")
+	}
+
+	var stepErr *StepError
+	if !errors.As(results[0].Err, &stepErr) {
+		t.Fatalf("got error %v, want a step error", results[0].Err)
+	}
+	if stepErr.Timeout != 200*time.Millisecond || stepErr.Step != "metric" || stepErr.Side != SideBase {
+		t.Errorf("got %+v, want the base metric step timed out after 200ms", stepErr)
+	}
+	if want := "timed out after 200ms in base (main)"; !strings.Contains(stepErr.Error(), want) {
+		t.Errorf("message %q does not say it %s", stepErr.Error(), want)
+	}
+
+	// The whole command was killed, not just the shell running it
+	time.Sleep(1500 * time.Millisecond)
+	if _, err := os.Stat(late); err == nil {
+		t.Error("the timed out command kept running")
+	}
+}
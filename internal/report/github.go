@@ -0,0 +1,262 @@
+package report
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"os"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/tiernacity/ratchet/internal/ratchet"
+)
+
+// GitHubActions reports whether ratchet is running in a GitHub Actions workflow
+func GitHubActions() bool {
+	return os.Getenv("GITHUB_ACTIONS") == "true"
+}
+
+// WriteGitHubGroups writes the captured output of each step of a result as
+// workflow commands, one collapsible group per side. The output is echoed
+// between stop-commands markers, since it may hold lines such as "::error::"
+// from the files a metric searched, which the runner would otherwise obey.
+func WriteGitHubGroups(w io.Writer, res ratchet.Result) {
+	for _, name := range []string{ratchet.SideBase, ratchet.SideHead} {
+		var steps []ratchet.StepResult
+		for _, step := range res.Steps {
+			if step.Side == name {
+				steps = append(steps, step)
+			}
+		}
+		if len(steps) == 0 {
+			continue
+		}
+
+		title := name
+		if name == ratchet.SideBase {
+			title += " (" + res.Metric.BaseRef + ")"
+		} else if res.HeadRef != "" {
+			title += " (" + res.HeadRef + ")"
+		}
+		if res.Metric.Name != "" {
+			title = res.Metric.Name + ": " + title
+		}
+
+		token := stopToken()
+		fmt.Fprintf(w, "::group::%s\n", escapeData(title))
+		fmt.Fprintf(w, "::stop-commands::%s\n", token)
+		for _, step := range steps {
+			fmt.Fprintf(w, "$ %s  [%s, %.1fs]\n", step.Command, step.Step, step.Duration.Seconds())
+			for _, output := range []string{step.Stdout, step.Stderr} {
+				if output == "" {
+					continue
+				}
+				fmt.Fprint(w, output)
+				if !strings.HasSuffix(output, "\n") {
+					fmt.Fprintln(w)
+				}
+			}
+			if step.Err != nil {
+				fmt.Fprintf(w, "%s step failed: %v\n", step.Step, step.Err)
+			}
+		}
+		fmt.Fprintf(w, "::%s::\n", token)
+		fmt.Fprintln(w, "::endgroup::")
+	}
+}
+
+// stopToken returns a random token for a stop-commands marker, which output
+// cannot guess in order to resume workflow commands early
+func stopToken() string {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		// Does not happen on supported platforms, and the output was
+		// written before the time could be known
+		return fmt.Sprintf("ratchet-%d", time.Now().UnixNano())
+	}
+	return hex.EncodeToString(b)
+}
+
+// WriteGitHubAnnotations writes an error annotation for every metric that
+// failed and a notice for every metric that passed. runErr is the error
+// returned by ratchet.Run; it is annotated only if no metric explains it.
+func WriteGitHubAnnotations(w io.Writer, results []ratchet.Result, runErr error) {
+	if runErr != nil && len(results) == 0 {
+		fmt.Fprintf(w, "::error title=ratchet::%s\n", escapeData(runErr.Error()))
+		return
+	}
+
+	for _, res := range results {
+		title := "ratchet"
+		if res.Metric.Name != "" {
+			title += ": " + res.Metric.Name
+		}
+
+		switch {
+		case res.Err != nil:
+			msg := res.Err.Error()
+			if cause := errorCause(res.Err); cause != "" {
+				msg += "\n" + cause
+			}
+			fmt.Fprintf(w, "::error title=%s::%s\n", escapeProperty(title), escapeData(msg))
+		case res.Metric.ComparisonType == ratchet.NoComparison:
+			fmt.Fprintf(w, "::notice title=%s::%g\n", escapeProperty(title), res.HeadValue)
+		case res.Override != nil:
+			fmt.Fprintf(w, "::warning title=%s::%s\n", escapeProperty(title), escapeData(res.Status()+"\n"+allowedBy(res.Override)))
+		case res.BaseErr != nil:
+			fmt.Fprintf(w, "::warning title=%s::%s\n", escapeProperty(title), escapeData(res.Status()+"\n"+res.BaseErr.Error()))
+		case res.Passed:
+			fmt.Fprintf(w, "::notice title=%s::%s\n", escapeProperty(title), escapeData(res.Status()))
+		default:
+			fmt.Fprintf(w, "::error title=%s::%s\n", escapeProperty(title), escapeData(res.Status()))
+		}
+	}
+}
+
+// WriteGitHubWarning writes a warning annotation, whose message may span
+// several lines
+func WriteGitHubWarning(w io.Writer, title string, message string) {
+	fmt.Fprintf(w, "::warning title=%s::%s\n", escapeProperty(title), escapeData(message))
+}
+
+// WriteGitHubSummary writes the results of a run as a markdown table, for
+// appending to the file named by GITHUB_STEP_SUMMARY
+func WriteGitHubSummary(w io.Writer, results []ratchet.Result, runErr error) error {
+	var b strings.Builder
+	b.WriteString("## Ratchet\n\n")
+
+	if runErr != nil && len(results) == 0 {
+		fmt.Fprintf(&b, ":x: %s\n\n", escapeCell(runErr.Error()))
+	} else {
+		b.WriteString("| Metric | Base | Base value | HEAD value | Test | Result |\n")
+		b.WriteString("| --- | --- | ---: | ---: | :---: | --- |\n")
+		for _, res := range results {
+			m := res.Metric
+			name := m.Name
+			if name == "" {
+				name = "`" + m.Command + "`"
+			}
+			base, baseValue, headValue := "-", "-", "-"
+			if m.ComparisonType != ratchet.NoComparison {
+				base = "`" + m.BaseRef + "`"
+			}
+
+			var status string
+			switch {
+			case res.Err != nil:
+				status = ":x: error"
+			case m.ComparisonType == ratchet.NoComparison:
+				headValue = fmt.Sprintf("%g", res.HeadValue)
+				status = "reported"
+			default:
+				baseValue = fmt.Sprintf("%g", res.BaseValue)
+				headValue = fmt.Sprintf("%g", res.HeadValue)
+				status = ":white_check_mark: passed"
+				if res.Override != nil {
+					status = ":warning: allowed"
+				} else if res.BaseErr != nil && m.BaseFailure == ratchet.BaseFailureWarn {
+					baseValue = "-"
+					status = ":warning: not compared"
+				} else if !res.Passed {
+					status = ":x: failed"
+				}
+			}
+			fmt.Fprintf(&b, "| %s | %s | %s | %s | %s | %s |\n",
+				escapeCell(name), escapeCell(base), baseValue, headValue,
+				escapeCell(m.ComparisonType.Symbol()), status)
+		}
+
+		// Explain each failure below the table
+		b.WriteString("\n")
+		for _, res := range results {
+			var detail string
+			switch {
+			case res.Err != nil:
+				detail = res.Err.Error()
+			case res.Metric.ComparisonType != ratchet.NoComparison && !res.Passed:
+				detail = res.Status()
+			case res.Override != nil:
+				detail = res.Status() + " (" + allowedBy(res.Override) + ")"
+			case res.BaseErr != nil:
+				detail = res.Status() + " (" + res.BaseErr.Error() + ")"
+			default:
+				continue
+			}
+			if res.Metric.Name != "" {
+				detail = "**" + res.Metric.Name + "**: " + detail
+			}
+			fmt.Fprintf(&b, "- %s\n", detail)
+		}
+	}
+
+	if _, err := io.WriteString(w, b.String()); err != nil {
+		return fmt.Errorf("failed to write step summary: %w", err)
+	}
+	return nil
+}
+
+// WriteGitHubOutputs writes base-value, head-value and passed as step outputs,
+// for appending to the file named by GITHUB_OUTPUT. With several metrics each
+// value is prefixed with the metric name and passed covers the whole run.
+func WriteGitHubOutputs(w io.Writer, results []ratchet.Result, runErr error) error {
+	var b strings.Builder
+	for _, res := range results {
+		prefix := ""
+		if len(results) > 1 {
+			prefix = outputName(res.Metric.Name) + "-"
+		}
+		if res.Measured {
+			if res.Metric.ComparisonType != ratchet.NoComparison {
+				fmt.Fprintf(&b, "%sbase-value=%g\n", prefix, res.BaseValue)
+			}
+			fmt.Fprintf(&b, "%shead-value=%g\n", prefix, res.HeadValue)
+		}
+		if prefix != "" {
+			fmt.Fprintf(&b, "%spassed=%t\n", prefix, res.Err == nil && res.Passed)
+		}
+	}
+	fmt.Fprintf(&b, "passed=%t\n", runErr == nil)
+
+	if _, err := io.WriteString(w, b.String()); err != nil {
+		return fmt.Errorf("failed to write step outputs: %w", err)
+	}
+	return nil
+}
+
+var outputNameInvalid = regexp.MustCompile(`[^A-Za-z0-9_-]+`)
+
+// allowedBy says which commit allowed a failed comparison, and why
+func allowedBy(o *ratchet.Override) string {
+	s := fmt.Sprintf("allowed by %s trailer on %s", ratchet.AllowTrailer, o.Commit)
+	if o.Reason != "" {
+		s += ": " + o.Reason
+	}
+	return s
+}
+
+// outputName turns a metric name into a valid step output name
+func outputName(name string) string {
+	return outputNameInvalid.ReplaceAllString(name, "-")
+}
+
+// escapeData escapes the message of a workflow command
+func escapeData(s string) string {
+	s = strings.ReplaceAll(s, "%", "%25")
+	s = strings.ReplaceAll(s, "\r", "%0D")
+	return strings.ReplaceAll(s, "\n", "%0A")
+}
+
+// escapeProperty escapes a property value of a workflow command
+func escapeProperty(s string) string {
+	s = escapeData(s)
+	s = strings.ReplaceAll(s, ":", "%3A")
+	return strings.ReplaceAll(s, ",", "%2C")
+}
+
+// escapeCell escapes text for a markdown table cell
+func escapeCell(s string) string {
+	s = strings.ReplaceAll(s, "|", "\\|")
+	return strings.ReplaceAll(s, "\n", " ")
+}
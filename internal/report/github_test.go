@@ -0,0 +1,145 @@
+package report
+
+import (
+	"bytes"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/tiernacity/ratchet/internal/ratchet"
+)
+
+func TestWriteGitHubGroupsStopsCommandsInOutput(t *testing.T) {
+	res := ratchet.Result{
+		Metric:  ratchet.Metric{Name: "todos", Command: "grep -rn TODO .", BaseRef: "main"},
+		HeadRef: "feature",
+		Steps: []ratchet.StepResult{
+			{Side: ratchet.SideHead, Step: "metric", Command: "grep -rn TODO .",
+				Stdout: "a.go:1:// TODO\nb.txt:2:::endgroup::\nc.txt:3:::error::injected\n"},
+		},
+	}
+
+	var buf bytes.Buffer
+	WriteGitHubGroups(&buf, res)
+	lines := strings.Split(strings.TrimSuffix(buf.String(), "\n"), "\n")
+
+	if lines[0] != "::group::todos: head (feature)" {
+		t.Errorf("first line %q, want the group", lines[0])
+	}
+	token, ok := strings.CutPrefix(lines[1], "::stop-commands::")
+	if !ok || len(token) < 16 {
+		t.Fatalf("second line %q, want a stop-commands marker with a random token", lines[1])
+	}
+	if got := lines[len(lines)-2]; got != "::"+token+"::" {
+		t.Errorf("line before endgroup %q, want commands resumed with the token", got)
+	}
+	if got := lines[len(lines)-1]; got != "::endgroup::" {
+		t.Errorf("last line %q, want endgroup", got)
+	}
+
+	// The echoed output must all fall between the markers
+	start, end := 2, len(lines)-2
+	body := strings.Join(lines[start:end], "\n")
+	for _, want := range []string{"b.txt:2:::endgroup::", "c.txt:3:::error::injected"} {
+		if !strings.Contains(body, want) {
+			t.Errorf("output %q missing between the markers", want)
+		}
+	}
+
+	// Each group has a token of its own
+	var again bytes.Buffer
+	WriteGitHubGroups(&again, res)
+	if strings.Contains(again.String(), token) {
+		t.Error("token reused between calls")
+	}
+}
+
+func TestWriteGitHubOutputs(t *testing.T) {
+	single := []ratchet.Result{{
+		Metric:    ratchet.Metric{Command: "echo 3", BaseRef: "main", ComparisonType: ratchet.LessEqual},
+		BaseValue: 4, HeadValue: 3, Measured: true, Passed: true,
+	}}
+	multi := []ratchet.Result{
+		{
+			Metric:    ratchet.Metric{Name: "lint warnings", Command: "lint", BaseRef: "main", ComparisonType: ratchet.LessEqual},
+			BaseValue: 2, HeadValue: 5, Measured: true, Passed: false,
+		},
+		{
+			Metric:    ratchet.Metric{Name: "size", Command: "du"},
+			HeadValue: 10, Measured: true, Passed: true,
+		},
+		{
+			Metric: ratchet.Metric{Name: "broken", Command: "false", BaseRef: "main", ComparisonType: ratchet.LessEqual},
+			Err:    errors.New("Metric command 'false' failed in main"),
+		},
+	}
+
+	tests := []struct {
+		name    string
+		results []ratchet.Result
+		runErr  error
+		want    string
+	}{
+		{"single", single, nil, "base-value=4\nhead-value=3\npassed=true\n"},
+		{"several", multi, ratchet.ErrComparisonFailed,
+			"lint-warnings-base-value=2\nlint-warnings-head-value=5\nlint-warnings-passed=false\n" +
+				"size-head-value=10\nsize-passed=true\n" +
+				"broken-passed=false\n" +
+				"passed=false\n"},
+		{"no results", nil, errors.New("not a git repository"), "passed=false\n"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var buf bytes.Buffer
+			if err := WriteGitHubOutputs(&buf, tt.results, tt.runErr); err != nil {
+				t.Fatal(err)
+			}
+			if buf.String() != tt.want {
+				t.Errorf("got\n%s\nwant\n%s", buf.String(), tt.want)
+			}
+		})
+	}
+}
+
+func TestWriteGitHubSummary(t *testing.T) {
+	results := []ratchet.Result{
+		{
+			Metric:    ratchet.Metric{Name: "todos", Command: "grep -c TODO", BaseRef: "main", ComparisonType: ratchet.LessEqual},
+			BaseValue: 2, HeadValue: 5, Measured: true,
+		},
+		{
+			Metric:    ratchet.Metric{Command: "echo a|b", BaseRef: "main", ComparisonType: ratchet.GreaterEqual},
+			BaseValue: 1, HeadValue: 1, Measured: true, Passed: true,
+		},
+	}
+
+	var buf bytes.Buffer
+	if err := WriteGitHubSummary(&buf, results, ratchet.ErrComparisonFailed); err != nil {
+		t.Fatal(err)
+	}
+	got := buf.String()
+	for _, want := range []string{
+		"## Ratchet\n",
+		"| Metric | Base | Base value | HEAD value | Test | Result |\n",
+		"| todos | `main` | 2 | 5 | <= | :x: failed |\n",
+		"| `echo a\\|b` | `main` | 1 | 1 | >= | :white_check_mark: passed |\n",
+		"- **todos**: ",
+	} {
+		if !strings.Contains(got, want) {
+			t.Errorf("summary missing %q:\n%s", want, got)
+		}
+	}
+	if strings.Count(got, "\n- ") != 1 {
+		t.Errorf("want one failure explained below the table:\n%s", got)
+	}
+}
+
+func TestWriteGitHubSummaryRunError(t *testing.T) {
+	var buf bytes.Buffer
+	if err := WriteGitHubSummary(&buf, nil, errors.New("base branch 'main' not found")); err != nil {
+		t.Fatal(err)
+	}
+	if want := "## Ratchet\n\n:x: base branch 'main' not found\n\n"; buf.String() != want {
+		t.Errorf("got %q, want %q", buf.String(), want)
+	}
+}
@@ -0,0 +1,147 @@
+package report
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/tiernacity/ratchet/internal/ratchet"
+)
+
+// jsonHistory is the top level of the JSON history output
+type jsonHistory struct {
+	Version int                 `json:"version"`
+	Metrics []jsonHistoryMetric `json:"metrics"`
+	Points  []jsonHistoryPoint  `json:"points"`
+}
+
+type jsonHistoryMetric struct {
+	Name    string `json:"name"`
+	Command string `json:"command"`
+}
+
+type jsonHistoryPoint struct {
+	Commit  string             `json:"commit"`
+	Date    string             `json:"date"`
+	Subject string             `json:"subject"`
+	Values  []jsonHistoryValue `json:"values"`
+}
+
+type jsonHistoryValue struct {
+	Value  *float64 `json:"value"`
+	Source string   `json:"source"`
+	Error  *string  `json:"error"`
+}
+
+// WriteHistoryTable writes a history series as an aligned table with one
+// column per metric
+func WriteHistoryTable(w io.Writer, metrics []ratchet.Metric, points []ratchet.HistoryPoint) error {
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	header := []string{"COMMIT", "DATE"}
+	for _, m := range metrics {
+		header = append(header, strings.ToUpper(historyColumn(m)))
+	}
+	fmt.Fprintln(tw, strings.Join(header, "\t"))
+
+	for _, p := range points {
+		row := []string{p.Commit.SHA[:min(len(p.Commit.SHA), 10)], p.Commit.Date.Format("2006-01-02")}
+		for _, v := range p.Values {
+			if v.Err != nil {
+				row = append(row, "error")
+				continue
+			}
+			row = append(row, fmt.Sprintf("%g", v.Value))
+		}
+		fmt.Fprintln(tw, strings.Join(row, "\t"))
+	}
+
+	if err := tw.Flush(); err != nil {
+		return fmt.Errorf("failed to write history: %w", err)
+	}
+	return nil
+}
+
+// WriteHistoryCSV writes a history series as CSV with a header row. Values that
+// could not be obtained are left empty.
+func WriteHistoryCSV(w io.Writer, metrics []ratchet.Metric, points []ratchet.HistoryPoint) error {
+	cw := csv.NewWriter(w)
+	header := []string{"commit", "date", "subject"}
+	for _, m := range metrics {
+		header = append(header, historyColumn(m))
+	}
+	if err := cw.Write(header); err != nil {
+		return fmt.Errorf("failed to write history: %w", err)
+	}
+
+	for _, p := range points {
+		row := []string{p.Commit.SHA, p.Commit.Date.Format(time.RFC3339), p.Commit.Subject}
+		for _, v := range p.Values {
+			if v.Err != nil {
+				row = append(row, "")
+				continue
+			}
+			row = append(row, strconv.FormatFloat(v.Value, 'g', -1, 64))
+		}
+		if err := cw.Write(row); err != nil {
+			return fmt.Errorf("failed to write history: %w", err)
+		}
+	}
+
+	cw.Flush()
+	if err := cw.Error(); err != nil {
+		return fmt.Errorf("failed to write history: %w", err)
+	}
+	return nil
+}
+
+// WriteHistoryJSON writes a history series as JSON, versioned alongside the
+// comparison output
+func WriteHistoryJSON(w io.Writer, metrics []ratchet.Metric, points []ratchet.HistoryPoint) error {
+	doc := jsonHistory{
+		Version: SchemaVersion,
+		Metrics: make([]jsonHistoryMetric, 0, len(metrics)),
+		Points:  make([]jsonHistoryPoint, 0, len(points)),
+	}
+	for _, m := range metrics {
+		doc.Metrics = append(doc.Metrics, jsonHistoryMetric{Name: m.Name, Command: m.Command})
+	}
+	for _, p := range points {
+		jp := jsonHistoryPoint{
+			Commit:  p.Commit.SHA,
+			Date:    p.Commit.Date.Format(time.RFC3339),
+			Subject: p.Commit.Subject,
+			Values:  make([]jsonHistoryValue, 0, len(p.Values)),
+		}
+		for _, v := range p.Values {
+			jv := jsonHistoryValue{Error: errorString(v.Err)}
+			if v.Err == nil {
+				jv.Value = floatPtr(v.Value)
+				jv.Source = v.Origin
+			}
+			jp.Values = append(jp.Values, jv)
+		}
+		doc.Points = append(doc.Points, jp)
+	}
+
+	data, err := json.MarshalIndent(doc, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode JSON history: %w", err)
+	}
+	if _, err := fmt.Fprintf(w, "%s\n", data); err != nil {
+		return fmt.Errorf("failed to write JSON history: %w", err)
+	}
+	return nil
+}
+
+// historyColumn names the column for a metric, "value" if it is unnamed
+func historyColumn(m ratchet.Metric) string {
+	if m.Name != "" {
+		return m.Name
+	}
+	return "value"
+}
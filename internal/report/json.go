@@ -0,0 +1,217 @@
+package report
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+
+	"github.com/tiernacity/ratchet/internal/ratchet"
+)
+
+// SchemaVersion is incremented whenever the JSON output changes incompatibly
+const SchemaVersion = 1
+
+// jsonReport is the top level of the JSON output
+type jsonReport struct {
+	Version int          `json:"version"`
+	Passed  bool         `json:"passed"`
+	Error   *string      `json:"error"`
+	Metrics []jsonMetric `json:"metrics"`
+}
+
+// jsonMetric is the JSON representation of a ratchet.Result
+type jsonMetric struct {
+	Name         string            `json:"name"`
+	Command      string            `json:"command"`
+	Comparison   string            `json:"comparison"`
+	BaseRef      string            `json:"base_ref"`
+	BaseSHA      string            `json:"base_sha"`
+	HeadSHA      string            `json:"head_sha"`
+	BaseSource   string            `json:"base_source"`
+	BaseError    *string           `json:"base_error"`
+	BaseValue    *float64          `json:"base_value"`
+	HeadValue    *float64          `json:"head_value"`
+	Tolerance    string            `json:"tolerance"`
+	Limit        *float64          `json:"limit"`
+	Margin       *float64          `json:"margin"`
+	Passed       bool              `json:"passed"`
+	Error        *string           `json:"error"`
+	Items        *jsonItems        `json:"items"`
+	Keys         []jsonKey         `json:"keys"`
+	Findings     []jsonFinding     `json:"findings"`
+	ChangedLines *jsonChangedLines `json:"changed_lines"`
+	Override     *jsonOverride     `json:"override"`
+	Steps        []jsonStep        `json:"steps"`
+}
+
+// jsonKey is the JSON representation of a ratchet.KeyChange
+type jsonKey struct {
+	Key    string   `json:"key"`
+	Base   *float64 `json:"base"`
+	Head   *float64 `json:"head"`
+	Reason string   `json:"reason"`
+}
+
+// jsonChangedLines is the JSON representation of a ratchet.LineCoverage
+type jsonChangedLines struct {
+	Coverable int        `json:"coverable"`
+	Uncovered []jsonLine `json:"uncovered"`
+}
+
+// jsonLine identifies one line of a source file
+type jsonLine struct {
+	File string `json:"file"`
+	Line int    `json:"line"`
+}
+
+// jsonFinding is the JSON representation of a new sarif.Finding
+type jsonFinding struct {
+	RuleID  string `json:"rule_id"`
+	Message string `json:"message"`
+	File    string `json:"file"`
+	Line    int    `json:"line"`
+}
+
+// jsonOverride is the JSON representation of a ratchet.Override
+type jsonOverride struct {
+	Trailer string `json:"trailer"`
+	Commit  string `json:"commit"`
+	Reason  string `json:"reason"`
+}
+
+// jsonItems is the JSON representation of a ratchet.ItemDiff
+type jsonItems struct {
+	Added   []string `json:"added"`
+	Removed []string `json:"removed"`
+}
+
+// jsonStep is the JSON representation of a ratchet.StepResult
+type jsonStep struct {
+	Side       string  `json:"side"`
+	Step       string  `json:"step"`
+	Command    string  `json:"command"`
+	DurationMS int64   `json:"duration_ms"`
+	Error      *string `json:"error"`
+}
+
+// WriteJSON writes the results of a run as JSON. runErr is the error returned
+// by ratchet.Run; it is recorded at the top level only if no metric explains
+// it, such as when the run could not start.
+func WriteJSON(w io.Writer, results []ratchet.Result, runErr error) error {
+	doc := jsonReport{
+		Version: SchemaVersion,
+		Passed:  runErr == nil,
+		Metrics: make([]jsonMetric, 0, len(results)),
+	}
+	if runErr != nil && len(results) == 0 {
+		doc.Error = errorString(runErr)
+	}
+
+	for _, res := range results {
+		m := res.Metric
+		jm := jsonMetric{
+			Name:       m.Name,
+			Command:    m.Command,
+			Comparison: comparisonName(m.ComparisonType),
+			HeadSHA:    res.HeadCommit,
+			Passed:     res.Measured && res.Passed,
+			Error:      errorString(res.Err),
+			Steps:      make([]jsonStep, 0, len(res.Steps)),
+		}
+		if m.ComparisonType != ratchet.NoComparison {
+			jm.BaseRef = m.BaseRef
+			jm.BaseSHA = res.BaseCommit
+			jm.BaseSource = res.BaseOrigin
+			jm.BaseError = errorString(res.BaseErr)
+			if !m.Tolerance.IsZero() {
+				jm.Tolerance = m.Tolerance.String()
+			}
+		}
+		if res.Measured {
+			jm.HeadValue = floatPtr(res.HeadValue)
+			if m.ComparisonType != ratchet.NoComparison && (res.BaseErr == nil || m.BaseFailure != ratchet.BaseFailureWarn) {
+				jm.BaseValue = floatPtr(res.BaseValue)
+				jm.Limit = floatPtr(res.Limit)
+				jm.Margin = floatPtr(res.Margin)
+			}
+		}
+		if res.Items != nil {
+			jm.Items = &jsonItems{Added: nonNil(res.Items.Added), Removed: nonNil(res.Items.Removed)}
+		}
+		if m.Keys.Enabled && res.Measured && m.ComparisonType != ratchet.NoComparison {
+			jm.Keys = make([]jsonKey, 0, len(res.Keys))
+			for _, k := range res.Keys {
+				jk := jsonKey{Key: k.Key, Base: floatPtr(k.Base), Head: floatPtr(k.Head), Reason: "worse"}
+				switch {
+				case k.New:
+					jk.Base, jk.Reason = nil, "new"
+				case k.Deleted:
+					jk.Head, jk.Reason = nil, "deleted"
+				}
+				jm.Keys = append(jm.Keys, jk)
+			}
+		}
+		if res.Findings != nil {
+			jm.Findings = make([]jsonFinding, 0, len(res.Findings.New))
+			for _, f := range res.Findings.New {
+				jm.Findings = append(jm.Findings, jsonFinding{RuleID: f.RuleID, Message: f.Message, File: f.File, Line: f.Line})
+			}
+		}
+		if res.Coverage != nil {
+			jm.ChangedLines = &jsonChangedLines{Coverable: res.Coverage.Coverable, Uncovered: make([]jsonLine, 0, len(res.Coverage.Uncovered))}
+			for _, l := range res.Coverage.Uncovered {
+				jm.ChangedLines.Uncovered = append(jm.ChangedLines.Uncovered, jsonLine{File: l.File, Line: l.Line})
+			}
+		}
+		if res.Override != nil {
+			jm.Override = &jsonOverride{Trailer: ratchet.AllowTrailer, Commit: res.Override.Commit, Reason: res.Override.Reason}
+		}
+		for _, step := range res.Steps {
+			jm.Steps = append(jm.Steps, jsonStep{
+				Side:       step.Side,
+				Step:       step.Step,
+				Command:    step.Command,
+				DurationMS: step.Duration.Milliseconds(),
+				Error:      errorString(step.Err),
+			})
+		}
+		doc.Metrics = append(doc.Metrics, jm)
+	}
+
+	data, err := json.MarshalIndent(doc, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode JSON results: %w", err)
+	}
+	if _, err := fmt.Fprintf(w, "%s\n", data); err != nil {
+		return fmt.Errorf("failed to write JSON results: %w", err)
+	}
+	return nil
+}
+
+// comparisonName returns the comparison as named in reports
+func comparisonName(ct ratchet.ComparisonType) string {
+	if ct == ratchet.NoComparison {
+		return "none"
+	}
+	return ct.String()
+}
+
+func floatPtr(v float64) *float64 {
+	return &v
+}
+
+func errorString(err error) *string {
+	if err == nil {
+		return nil
+	}
+	s := err.Error()
+	return &s
+}
+
+// nonNil returns items, or an empty list so that JSON has [] rather than null
+func nonNil(items []string) []string {
+	if items == nil {
+		return []string{}
+	}
+	return items
+}
@@ -0,0 +1,192 @@
+package report
+
+import (
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	"github.com/tiernacity/ratchet/internal/ratchet"
+)
+
+// junitTestSuites is the root element of a JUnit XML report
+type junitTestSuites struct {
+	XMLName  xml.Name         `xml:"testsuites"`
+	Name     string           `xml:"name,attr"`
+	Tests    int              `xml:"tests,attr"`
+	Failures int              `xml:"failures,attr"`
+	Errors   int              `xml:"errors,attr"`
+	Time     string           `xml:"time,attr"`
+	Suites   []junitTestSuite `xml:"testsuite"`
+}
+
+type junitTestSuite struct {
+	Name      string          `xml:"name,attr"`
+	Tests     int             `xml:"tests,attr"`
+	Failures  int             `xml:"failures,attr"`
+	Errors    int             `xml:"errors,attr"`
+	Time      string          `xml:"time,attr"`
+	Timestamp string          `xml:"timestamp,attr"`
+	Cases     []junitTestCase `xml:"testcase"`
+}
+
+type junitTestCase struct {
+	Name       string          `xml:"name,attr"`
+	Classname  string          `xml:"classname,attr"`
+	Time       string          `xml:"time,attr"`
+	Properties []junitProperty `xml:"properties>property,omitempty"`
+	Failure    *junitProblem   `xml:"failure,omitempty"`
+	Error      *junitProblem   `xml:"error,omitempty"`
+	SystemErr  *junitText      `xml:"system-err,omitempty"`
+}
+
+// junitText is element content written as CDATA to keep it readable
+type junitText struct {
+	Text string `xml:",cdata"`
+}
+
+type junitProperty struct {
+	Name  string `xml:"name,attr"`
+	Value string `xml:"value,attr"`
+}
+
+// junitProblem is the body of a failure or error element
+type junitProblem struct {
+	Message string `xml:"message,attr"`
+	Type    string `xml:"type,attr"`
+	Body    string `xml:",cdata"`
+}
+
+// WriteJUnit writes the results of a run as a JUnit XML report with one
+// testcase per metric. runErr is the error returned by ratchet.Run; if the run
+// could not start it is reported as an error in a single "ratchet" testcase.
+func WriteJUnit(w io.Writer, results []ratchet.Result, runErr error) error {
+	suite := junitTestSuite{
+		Name:      "ratchet",
+		Timestamp: time.Now().UTC().Format("2006-01-02T15:04:05"),
+	}
+
+	var total time.Duration
+	for _, res := range results {
+		tc, elapsed := junitCase(res)
+		total += elapsed
+		if tc.Failure != nil {
+			suite.Failures++
+		}
+		if tc.Error != nil {
+			suite.Errors++
+		}
+		suite.Cases = append(suite.Cases, tc)
+	}
+	if runErr != nil && len(results) == 0 {
+		suite.Errors++
+		suite.Cases = append(suite.Cases, junitTestCase{
+			Name:      "ratchet",
+			Classname: "ratchet",
+			Time:      seconds(0),
+			Error:     &junitProblem{Message: runErr.Error(), Type: "error", Body: runErr.Error()},
+		})
+	}
+	suite.Tests = len(suite.Cases)
+	suite.Time = seconds(total)
+
+	doc := junitTestSuites{
+		Name:     "ratchet",
+		Tests:    suite.Tests,
+		Failures: suite.Failures,
+		Errors:   suite.Errors,
+		Time:     suite.Time,
+		Suites:   []junitTestSuite{suite},
+	}
+
+	data, err := xml.MarshalIndent(doc, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode JUnit report: %w", err)
+	}
+	if _, err := fmt.Fprintf(w, "%s%s\n", xml.Header, data); err != nil {
+		return fmt.Errorf("failed to write JUnit report: %w", err)
+	}
+	return nil
+}
+
+// junitCase converts a result into a testcase, returning the time its steps took
+func junitCase(res ratchet.Result) (junitTestCase, time.Duration) {
+	m := res.Metric
+	name := m.Name
+	if name == "" {
+		name = m.Command
+	}
+
+	var elapsed time.Duration
+	var stderr []string
+	for _, step := range res.Steps {
+		elapsed += step.Duration
+		if step.Stderr != "" {
+			stderr = append(stderr, fmt.Sprintf("[%s %s] %s\n%s", step.Side, step.Step, step.Command, step.Stderr))
+		}
+	}
+
+	tc := junitTestCase{
+		Name:      name,
+		Classname: "ratchet",
+		Time:      seconds(elapsed),
+	}
+	if len(stderr) > 0 {
+		tc.SystemErr = &junitText{Text: strings.Join(stderr, "\n\n")}
+	}
+
+	tc.Properties = append(tc.Properties,
+		junitProperty{Name: "command", Value: m.Command},
+		junitProperty{Name: "comparison", Value: comparisonName(m.ComparisonType)},
+	)
+	if m.ComparisonType != ratchet.NoComparison {
+		tc.Properties = append(tc.Properties,
+			junitProperty{Name: "base_ref", Value: m.BaseRef},
+			junitProperty{Name: "base_sha", Value: res.BaseCommit},
+		)
+	}
+	tc.Properties = append(tc.Properties, junitProperty{Name: "head_sha", Value: res.HeadCommit})
+	if res.Measured {
+		if m.ComparisonType != ratchet.NoComparison {
+			tc.Properties = append(tc.Properties, junitProperty{Name: "base_value", Value: fmt.Sprintf("%g", res.BaseValue)})
+		}
+		tc.Properties = append(tc.Properties, junitProperty{Name: "head_value", Value: fmt.Sprintf("%g", res.HeadValue)})
+	}
+	if !m.Tolerance.IsZero() {
+		tc.Properties = append(tc.Properties, junitProperty{Name: "tolerance", Value: m.Tolerance.String()})
+	}
+	if res.Override != nil {
+		tc.Properties = append(tc.Properties,
+			junitProperty{Name: "allowed_by", Value: res.Override.Commit},
+			junitProperty{Name: "allowed_reason", Value: res.Override.Reason})
+	}
+
+	switch {
+	case res.Err != nil:
+		body := res.Err.Error()
+		if cause := errorCause(res.Err); cause != "" {
+			body += "\n" + cause
+		}
+		tc.Error = &junitProblem{Message: res.Err.Error(), Type: "error", Body: body}
+	case !res.Passed:
+		status := res.Status()
+		tc.Failure = &junitProblem{Message: status, Type: "comparison", Body: status}
+	}
+
+	return tc, elapsed
+}
+
+// errorCause returns the message of the error wrapped by err, if any
+func errorCause(err error) string {
+	if cause := errors.Unwrap(err); cause != nil {
+		return cause.Error()
+	}
+	return ""
+}
+
+// seconds formats a duration as JUnit expects
+func seconds(d time.Duration) string {
+	return fmt.Sprintf("%.3f", d.Seconds())
+}
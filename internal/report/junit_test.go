@@ -0,0 +1,106 @@
+package report
+
+import (
+	"bytes"
+	"encoding/xml"
+	"errors"
+	"testing"
+
+	"github.com/tiernacity/ratchet/internal/ratchet"
+)
+
+func TestWriteJUnit(t *testing.T) {
+	metric := ratchet.Metric{Name: "todos", Command: "grep -c TODO", BaseRef: "main", ComparisonType: ratchet.LessEqual}
+	tests := []struct {
+		name     string
+		results  []ratchet.Result
+		runErr   error
+		cases    int
+		failures int
+		errors   int
+	}{
+		{
+			name:    "passed",
+			results: []ratchet.Result{{Metric: metric, Measured: true, Passed: true, BaseValue: 2, HeadValue: 2}},
+			cases:   1,
+		},
+		{
+			name: "failed and errored",
+			results: []ratchet.Result{
+				{Metric: metric, Measured: true, BaseValue: 2, HeadValue: 3},
+				{Metric: ratchet.Metric{Command: "false"}, Err: errors.New("metric command failed")},
+			},
+			runErr:   errors.New("1 comparison failed"),
+			cases:    2,
+			failures: 1,
+			errors:   1,
+		},
+		{
+			name:   "run could not start",
+			runErr: errors.New("not a git repository"),
+			cases:  1,
+			errors: 1,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var buf bytes.Buffer
+			if err := WriteJUnit(&buf, tt.results, tt.runErr); err != nil {
+				t.Fatal(err)
+			}
+			var doc junitTestSuites
+			if err := xml.Unmarshal(buf.Bytes(), &doc); err != nil {
+				t.Fatalf("report is not valid XML: %v\n%s", err, buf.String())
+			}
+			if doc.Tests != tt.cases || doc.Failures != tt.failures || doc.Errors != tt.errors {
+				t.Errorf("got %d tests, %d failures, %d errors; want %d, %d, %d",
+					doc.Tests, doc.Failures, doc.Errors, tt.cases, tt.failures, tt.errors)
+			}
+			if len(doc.Suites) != 1 || len(doc.Suites[0].Cases) != tt.cases {
+				t.Fatalf("want one suite with %d cases:\n%s", tt.cases, buf.String())
+			}
+		})
+	}
+}
+
+func TestJUnitCaseProperties(t *testing.T) {
+	tol, err := ratchet.ParseTolerance("1%")
+	if err != nil {
+		t.Fatal(err)
+	}
+	res := ratchet.Result{
+		Metric:     ratchet.Metric{Command: "cat count", BaseRef: "main", ComparisonType: ratchet.GreaterEqual, Tolerance: tol},
+		BaseCommit: "abc",
+		HeadCommit: "def",
+		Measured:   true,
+		Passed:     true,
+		BaseValue:  80,
+		HeadValue:  79.5,
+	}
+	tc, _ := junitCase(res)
+	if tc.Name != "cat count" {
+		t.Errorf("name %q, want the command for an unnamed metric", tc.Name)
+	}
+	want := map[string]string{
+		"command":    "cat count",
+		"comparison": "greater-equal",
+		"base_ref":   "main",
+		"base_sha":   "abc",
+		"head_sha":   "def",
+		"base_value": "80",
+		"head_value": "79.5",
+		"tolerance":  "1%",
+	}
+	got := make(map[string]string)
+	for _, p := range tc.Properties {
+		got[p.Name] = p.Value
+	}
+	for name, value := range want {
+		if got[name] != value {
+			t.Errorf("property %s = %q, want %q", name, got[name], value)
+		}
+	}
+	if tc.Failure != nil || tc.Error != nil {
+		t.Errorf("a passing metric has failure %v and error %v", tc.Failure, tc.Error)
+	}
+}
@@ -0,0 +1,157 @@
+package sarif
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// Finding is one result from a SARIF log
+type Finding struct {
+	RuleID  string
+	Message string
+	File    string // URI of the first location, if any
+	Line    int    // Start line of the first location, 0 if unknown
+
+	key   string // What the finding is matched on
+	run   int    // Index of its run in the log
+	index int    // Index of its result in the run
+}
+
+// log is the part of a SARIF log that is read. Filtering works on the raw
+// JSON instead, so that nothing else in the log is lost.
+type log struct {
+	Runs []struct {
+		Results []struct {
+			RuleID  string `json:"ruleId"`
+			Message struct {
+				Text string `json:"text"`
+			} `json:"message"`
+			Locations []struct {
+				PhysicalLocation struct {
+					ArtifactLocation struct {
+						URI string `json:"uri"`
+					} `json:"artifactLocation"`
+					Region struct {
+						StartLine int `json:"startLine"`
+					} `json:"region"`
+				} `json:"physicalLocation"`
+			} `json:"locations"`
+			PartialFingerprints map[string]string `json:"partialFingerprints"`
+		} `json:"results"`
+	} `json:"runs"`
+}
+
+// Parse returns every result in a SARIF log, in order
+func Parse(data string) ([]Finding, error) {
+	var l log
+	if err := json.Unmarshal([]byte(data), &l); err != nil {
+		return nil, fmt.Errorf("invalid SARIF: %w", err)
+	}
+
+	var findings []Finding
+	for r, run := range l.Runs {
+		for i, res := range run.Results {
+			f := Finding{RuleID: res.RuleID, Message: res.Message.Text, run: r, index: i}
+			if len(res.Locations) > 0 {
+				loc := res.Locations[0].PhysicalLocation
+				f.File = loc.ArtifactLocation.URI
+				f.Line = loc.Region.StartLine
+			}
+			f.key = matchKey(f, res.PartialFingerprints)
+			findings = append(findings, f)
+		}
+	}
+	return findings, nil
+}
+
+// matchKey identifies a finding across commits by its partial fingerprints,
+// or failing those by its rule, message and file, which unlike the line
+// survive unrelated edits
+func matchKey(f Finding, fingerprints map[string]string) string {
+	if len(fingerprints) == 0 {
+		return strings.Join([]string{"result", f.RuleID, f.Message, f.File}, "\x00")
+	}
+	names := make([]string, 0, len(fingerprints))
+	for name := range fingerprints {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	parts := []string{"fingerprint"}
+	for _, name := range names {
+		parts = append(parts, name, fingerprints[name])
+	}
+	return strings.Join(parts, "\x00")
+}
+
+// New returns the findings in head that have no match in base, in order.
+// Findings that match the same way are paired one for one, so another copy
+// of an existing finding is new.
+func New(base []Finding, head []Finding) []Finding {
+	remaining := make(map[string]int, len(base))
+	for _, f := range base {
+		remaining[f.key]++
+	}
+	var added []Finding
+	for _, f := range head {
+		if remaining[f.key] > 0 {
+			remaining[f.key]--
+		} else {
+			added = append(added, f)
+		}
+	}
+	return added
+}
+
+// Filter returns the SARIF log in data with only the given results, which
+// must have been parsed from it, left in each run
+func Filter(data string, keep []Finding) ([]byte, error) {
+	var doc map[string]interface{}
+	if err := json.Unmarshal([]byte(data), &doc); err != nil {
+		return nil, fmt.Errorf("invalid SARIF: %w", err)
+	}
+
+	kept := make(map[[2]int]bool, len(keep))
+	for _, f := range keep {
+		kept[[2]int{f.run, f.index}] = true
+	}
+	runs, _ := doc["runs"].([]interface{})
+	for r, run := range runs {
+		runMap, ok := run.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		results, _ := runMap["results"].([]interface{})
+		filtered := make([]interface{}, 0, len(results))
+		for i, res := range results {
+			if kept[[2]int{r, i}] {
+				filtered = append(filtered, res)
+			}
+		}
+		runMap["results"] = filtered
+	}
+
+	out, err := json.MarshalIndent(doc, "", "  ")
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode SARIF: %w", err)
+	}
+	return append(out, '\n'), nil
+}
+
+// String describes the finding in one line, e.g. "src/a.go:12: [G104] Errors unhandled"
+func (f Finding) String() string {
+	var b strings.Builder
+	if f.File != "" {
+		b.WriteString(f.File)
+		if f.Line > 0 {
+			fmt.Fprintf(&b, ":%d", f.Line)
+		}
+		b.WriteString(": ")
+	}
+	if f.RuleID != "" {
+		fmt.Fprintf(&b, "[%s] ", f.RuleID)
+	}
+	b.WriteString(f.Message)
+	return b.String()
+}
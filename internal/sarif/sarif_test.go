@@ -0,0 +1,165 @@
+package sarif
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+)
+
+// result builds a SARIF result as JSON
+func result(rule string, message string, file string, line int, fingerprint string) string {
+	r := map[string]interface{}{
+		"ruleId":  rule,
+		"message": map[string]string{"text": message},
+		"locations": []interface{}{map[string]interface{}{
+			"physicalLocation": map[string]interface{}{
+				"artifactLocation": map[string]string{"uri": file},
+				"region":           map[string]int{"startLine": line},
+			},
+		}},
+	}
+	if fingerprint != "" {
+		r["partialFingerprints"] = map[string]string{"primaryLocationLineHash": fingerprint}
+	}
+	data, _ := json.Marshal(r)
+	return string(data)
+}
+
+func TestNew(t *testing.T) {
+	tests := []struct {
+		name string
+		base string
+		head string
+		want []string
+	}{
+		{
+			name: "moved line still matches",
+			base: `{"runs": [{"results": [` + result("G104", "Errors unhandled", "a.go", 10, "") + `]}]}`,
+			head: `{"runs": [{"results": [` + result("G104", "Errors unhandled", "a.go", 14, "") + `]}]}`,
+		},
+		{
+			name: "different file is new",
+			base: `{"runs": [{"results": [` + result("G104", "Errors unhandled", "a.go", 10, "") + `]}]}`,
+			head: `{"runs": [{"results": [` + result("G104", "Errors unhandled", "b.go", 10, "") + `]}]}`,
+			want: []string{"b.go:10: [G104] Errors unhandled"},
+		},
+		{
+			name: "fingerprints match despite a new message",
+			base: `{"runs": [{"results": [` + result("R1", "old text", "a.go", 1, "abc") + `]}]}`,
+			head: `{"runs": [{"results": [` + result("R1", "new text", "a.go", 9, "abc") + `]}]}`,
+		},
+		{
+			name: "fingerprints differ despite the same message",
+			base: `{"runs": [{"results": [` + result("R1", "same", "a.go", 1, "abc") + `]}]}`,
+			head: `{"runs": [{"results": [` + result("R1", "same", "a.go", 1, "def") + `]}]}`,
+			want: []string{"a.go:1: [R1] same"},
+		},
+		{
+			name: "another copy is new",
+			base: `{"runs": [{"results": [` + result("R1", "m", "a.go", 1, "") + `]}]}`,
+			head: `{"runs": [{"results": [` + result("R1", "m", "a.go", 1, "") + `, ` + result("R1", "m", "a.go", 5, "") + `]}]}`,
+			want: []string{"a.go:5: [R1] m"},
+		},
+		{
+			name: "empty base run",
+			base: `{"runs": [{"tool": {"driver": {"name": "lint"}}}]}`,
+			head: `{"runs": [{"results": [` + result("R1", "m", "a.go", 1, "") + `]}]}`,
+			want: []string{"a.go:1: [R1] m"},
+		},
+		{
+			name: "empty head run",
+			base: `{"runs": [{"results": [` + result("R1", "m", "a.go", 1, "") + `]}]}`,
+			head: `{"runs": [{"results": []}]}`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			base, err := Parse(tt.base)
+			if err != nil {
+				t.Fatal(err)
+			}
+			head, err := Parse(tt.head)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var got []string
+			for _, f := range New(base, head) {
+				got = append(got, f.String())
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("got %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseInvalid(t *testing.T) {
+	if _, err := Parse("not json"); err == nil {
+		t.Error("expected invalid SARIF to be rejected")
+	}
+}
+
+func TestFilter(t *testing.T) {
+	data := `{"version": "2.1.0", "runs": [` +
+		`{"tool": {"driver": {"name": "a"}}, "results": [` + result("R1", "one", "a.go", 1, "") + `, ` + result("R2", "two", "a.go", 2, "") + `]},` +
+		`{"tool": {"driver": {"name": "b"}}},` +
+		`{"results": [` + result("R3", "three", "b.go", 3, "") + `]}]}`
+	findings, err := Parse(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(findings) != 3 {
+		t.Fatalf("parsed %d findings, want 3", len(findings))
+	}
+
+	// Keep the second result of the first run and the only one of the last
+	out, err := Filter(data, []Finding{findings[1], findings[2]})
+	if err != nil {
+		t.Fatal(err)
+	}
+	filtered, err := Parse(string(out))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got []string
+	for _, f := range filtered {
+		got = append(got, f.String())
+	}
+	if want := []string{"a.go:2: [R2] two", "b.go:3: [R3] three"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("filtered to %q, want %q", got, want)
+	}
+
+	// Everything else in the log is kept
+	var doc struct {
+		Version string `json:"version"`
+		Runs    []struct {
+			Tool    map[string]interface{} `json:"tool"`
+			Results []interface{}          `json:"results"`
+		} `json:"runs"`
+	}
+	if err := json.Unmarshal(out, &doc); err != nil {
+		t.Fatal(err)
+	}
+	if doc.Version != "2.1.0" || len(doc.Runs) != 3 || doc.Runs[0].Tool == nil || doc.Runs[1].Tool == nil {
+		t.Errorf("filtering lost parts of the log:\n%s", out)
+	}
+	if doc.Runs[1].Results == nil || len(doc.Runs[1].Results) != 0 {
+		t.Errorf("empty run has results %v, want an empty list", doc.Runs[1].Results)
+	}
+}
+
+func TestFindingString(t *testing.T) {
+	tests := []struct {
+		f    Finding
+		want string
+	}{
+		{Finding{RuleID: "R1", Message: "m", File: "a.go", Line: 3}, "a.go:3: [R1] m"},
+		{Finding{RuleID: "R1", Message: "m", File: "a.go"}, "a.go: [R1] m"},
+		{Finding{Message: "m"}, "m"},
+	}
+	for _, tt := range tests {
+		if got := tt.f.String(); got != tt.want {
+			t.Errorf("got %q, want %q", got, tt.want)
+		}
+	}
+}